@@ -2,98 +2,34 @@ package main
 
 import (
 	_ "embed"
-	"flag"
 	"fmt"
+	"os"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
-	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/commands"
-	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 )
 
 //go:embed ascii.txt
 var ascii string
-var emoticon = "♪(๑ᴖ◡ᴖ๑)♪"
 
 var Version = "dev"
 
-var debug *bool
-var serverDirectory *string
-var flushCache *bool
-var throttleNetwork *int
-
-func init() {
-	debug = flag.Bool("d", false, "enable debug logging")
-	serverDirectory = flag.String("dir", ".", "path to server")
-	flushCache = flag.Bool("flush-cache", false, "flush plugin cache before running")
-	throttleNetwork = flag.Int("throttle-network", 0, "throttle download speed in KB/s (for testing)")
-}
-
 func main() {
-	log.Info("Initializing Plugstep " + emoticon)
+	utils.UserAgent = fmt.Sprintf("plugstep/%s (+https://forgejo.perny.dev/mineframe/plugstep)", Version)
 
-	flag.Parse()
-
-	if debug != nil && *debug {
-		log.SetLevel(log.DebugLevel)
-	}
-	log.Debug("Debug logging enabled.")
-
-	if throttleNetwork != nil && *throttleNetwork > 0 {
-		utils.SetThrottledTransport(*throttleNetwork * 1024)
-		log.Debug("Network throttling enabled", "kb/s", *throttleNetwork)
-	}
-
-	if flushCache != nil && *flushCache {
-		// Initialize cache DB first so we can flush it
-		if err := utils.InitCacheDB(*serverDirectory); err != nil {
-			log.Debug("Failed to init cache for flush", "err", err)
-		}
-		if err := plugins.FlushCache(); err != nil {
-			log.Error("Failed to flush cache", "err", err)
-		}
-	}
+	root := commands.NewRootCommand(ShowVersion)
+	root.SetArgs(commands.NormalizeLegacyFlags(os.Args[1:]))
 
-	args := flag.Args()
-	log.Debug("Loaded args", "args", args)
-
-	if len(args) < 1 {
-		ShowVersion()
-		return
-	}
-	command := args[0]
-
-	switch command {
-	case "version", "v":
-		ShowVersion()
-		return
-	case "upgrade", "u":
-		targetVersion := ""
-		if len(args) > 1 {
-			targetVersion = args[1]
-		}
-		commands.UpgradeCommand(*serverDirectory, targetVersion)
-		return
-	case "plugin", "p":
-		commands.PluginCommand(args[1:], *serverDirectory)
-		return
-	}
-
-	ps := plugstep.CreatePlugstep(args, *serverDirectory)
-	err := ps.Init()
+	err := root.Execute()
+	// Closing the cache saves its hit statistics.
+	utils.CloseCache()
 	if err != nil {
-		return
-	}
-
-	switch command {
-	case "install", "i":
-		commands.InstallCommand(ps)
-		return
+		log.Error(err)
 	}
-
-	log.Info("Unknown command", "command", command)
+	os.Exit(int(exitcode.Of(err)))
 }
 
 func ShowVersion() {
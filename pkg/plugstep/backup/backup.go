@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	metadataFile = "snapshot.json"
+	filesDir     = "files"
+	idFormat     = "20060102-150405"
+)
+
+// Files captured verbatim at the start of every snapshot so a rollback also
+// restores the configuration and manifest that belonged to the old jars.
+var stateFiles = []string{
+	"plugstep.toml",
+	".plugstep/manifest.json",
+}
+
+// Snapshot collects the files an install is about to replace or remove. It is
+// only written to disk once something was actually saved into it.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Files existed before the install and are restored on rollback.
+	Files []string `json:"files"`
+	// Added didn't exist before the install and are deleted on rollback.
+	Added []string `json:"added"`
+
+	serverDirectory string
+	state           map[string][]byte
+	seen            map[string]bool
+	mu              sync.Mutex
+}
+
+// Dir returns the directory holding all snapshots of a server directory.
+func Dir(serverDirectory string) string {
+	return filepath.Join(serverDirectory, ".plugstep", "backups")
+}
+
+// Begin starts a snapshot, capturing the current config and manifest.
+func Begin(serverDirectory string) *Snapshot {
+	now := time.Now()
+	s := &Snapshot{
+		ID:              now.Format(idFormat),
+		CreatedAt:       now,
+		serverDirectory: serverDirectory,
+		state:           map[string][]byte{},
+		seen:            map[string]bool{},
+	}
+
+	base := s.ID
+	for i := 2; ; i++ {
+		if _, err := os.Stat(s.dir()); os.IsNotExist(err) {
+			break
+		}
+		s.ID = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	for _, rel := range stateFiles {
+		data, err := os.ReadFile(filepath.Join(serverDirectory, filepath.FromSlash(rel)))
+		if err == nil {
+			s.state[rel] = data
+		}
+	}
+
+	return s
+}
+
+// Save copies relPath into the snapshot before it gets replaced or removed.
+// Files that don't exist yet are remembered so rollback can delete them.
+func (s *Snapshot) Save(relPath string) error {
+	if s == nil {
+		return nil
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[relPath] {
+		return nil
+	}
+	s.seen[relPath] = true
+
+	src := filepath.Join(s.serverDirectory, filepath.FromSlash(relPath))
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		s.Added = append(s.Added, relPath)
+		return nil
+	}
+
+	dest := filepath.Join(s.dir(), filesDir, filepath.FromSlash(relPath))
+	if err := copyFile(src, dest); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", relPath, err)
+	}
+	s.Files = append(s.Files, relPath)
+	return nil
+}
+
+// Finish writes the snapshot metadata and prunes old snapshots down to keep
+// (0 keeps everything). Snapshots that saved nothing are discarded.
+func (s *Snapshot) Finish(keep int) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.Files) == 0 && len(s.Added) == 0 {
+		return nil
+	}
+
+	for rel, data := range s.state {
+		dest := filepath.Join(s.dir(), filepath.FromSlash(rel))
+		if err := utils.EnsureDirectory(filepath.Dir(dest)); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.EnsureDirectory(s.dir()); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir(), metadataFile), data, 0644); err != nil {
+		return err
+	}
+
+	log.Info("Saved backup", "id", s.ID, "files", len(s.Files))
+
+	return Prune(s.serverDirectory, keep)
+}
+
+func (s *Snapshot) dir() string {
+	return filepath.Join(Dir(s.serverDirectory), s.ID)
+}
+
+// List returns the snapshots of a server directory, newest first.
+func List(serverDirectory string) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(Dir(serverDirectory))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(Dir(serverDirectory), e.Name(), metadataFile))
+		if err != nil {
+			log.Debug("Skipping incomplete backup", "id", e.Name(), "err", err)
+			continue
+		}
+		s := &Snapshot{serverDirectory: serverDirectory}
+		if err := json.Unmarshal(data, s); err != nil {
+			log.Debug("Skipping unreadable backup", "id", e.Name(), "err", err)
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Restore rolls the server directory back to the snapshot with the given id,
+// or the newest snapshot when id is empty.
+func Restore(serverDirectory string, id string) (*Snapshot, error) {
+	snapshots, err := List(serverDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no backups found")
+	}
+
+	var s *Snapshot
+	if id == "" {
+		s = snapshots[0]
+	} else {
+		for _, candidate := range snapshots {
+			if candidate.ID == id {
+				s = candidate
+				break
+			}
+		}
+		if s == nil {
+			return nil, fmt.Errorf("backup not found: %s", id)
+		}
+	}
+
+	for _, rel := range s.Files {
+		src := filepath.Join(s.dir(), filesDir, filepath.FromSlash(rel))
+		dest := filepath.Join(serverDirectory, filepath.FromSlash(rel))
+		if err := copyFile(src, dest); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+	}
+
+	for _, rel := range s.Added {
+		err := os.Remove(filepath.Join(serverDirectory, filepath.FromSlash(rel)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", rel, err)
+		}
+	}
+
+	for _, rel := range stateFiles {
+		src := filepath.Join(s.dir(), filepath.FromSlash(rel))
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(serverDirectory, filepath.FromSlash(rel))); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+	}
+
+	return s, nil
+}
+
+// Prune deletes all but the newest keep snapshots. A keep of 0 disables
+// pruning.
+func Prune(serverDirectory string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	snapshots, err := List(serverDirectory)
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(snapshots); i++ {
+		if err := os.RemoveAll(snapshots[i].dir()); err != nil {
+			return err
+		}
+		log.Debug("Pruned backup", "id", snapshots[i].ID)
+	}
+	return nil
+}
+
+// copyFile copies src to dest through a temporary file renamed over dest, so
+// that a dest hard linked from the download store is replaced rather than
+// written through.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := utils.EnsureDirectory(filepath.Dir(dest)); err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dest)
+}
@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", rel, err)
+	}
+}
+
+func readFile(t *testing.T, dir, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", rel, err)
+	}
+	return string(data)
+}
+
+func TestSnapshot_EmptySnapshotIsDiscarded(t *testing.T) {
+	tempDir := t.TempDir()
+
+	s := Begin(tempDir)
+	if err := s.Finish(5); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+
+	snapshots, err := List(tempDir)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("expected no snapshots, got %d", len(snapshots))
+	}
+}
+
+func TestSnapshot_NilSnapshotIsNoop(t *testing.T) {
+	var s *Snapshot
+
+	if err := s.Save("plugins/a.jar"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.Finish(5); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRestore_RestoresReplacedAndRemovesAddedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, tempDir, "plugstep.toml", "old config")
+	writeFile(t, tempDir, "plugins/luckperms.jar", "old jar")
+
+	s := Begin(tempDir)
+	if err := s.Save("plugins/luckperms.jar"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := s.Save("plugins/chunky.jar"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	writeFile(t, tempDir, "plugins/luckperms.jar", "new jar")
+	writeFile(t, tempDir, "plugins/chunky.jar", "added jar")
+	writeFile(t, tempDir, "plugstep.toml", "new config")
+	if err := s.Finish(5); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+
+	restored, err := Restore(tempDir, "")
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	if restored.ID != s.ID {
+		t.Errorf("expected snapshot %q, got %q", s.ID, restored.ID)
+	}
+	if got := readFile(t, tempDir, "plugins/luckperms.jar"); got != "old jar" {
+		t.Errorf("expected old jar restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "plugins", "chunky.jar")); !os.IsNotExist(err) {
+		t.Error("expected added jar to be removed")
+	}
+	if got := readFile(t, tempDir, "plugstep.toml"); got != "old config" {
+		t.Errorf("expected old config restored, got %q", got)
+	}
+}
+
+func TestRestore_ReplacesHardLinkedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	store := t.TempDir()
+	writeFile(t, tempDir, "plugins/luckperms.jar", "old jar")
+	writeFile(t, store, "sha256/new", "new jar")
+
+	s := Begin(tempDir)
+	if err := s.Save("plugins/luckperms.jar"); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	jar := filepath.Join(tempDir, "plugins", "luckperms.jar")
+	os.Remove(jar)
+	if err := os.Link(filepath.Join(store, "sha256", "new"), jar); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	if err := s.Finish(5); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+
+	if _, err := Restore(tempDir, ""); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	if got := readFile(t, tempDir, "plugins/luckperms.jar"); got != "old jar" {
+		t.Errorf("expected old jar restored, got %q", got)
+	}
+	if got := readFile(t, store, "sha256/new"); got != "new jar" {
+		t.Errorf("expected the linked store file to be unchanged, got %q", got)
+	}
+}
+
+func TestRestore_UnknownIDFails(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, tempDir, "plugins/a.jar", "a")
+
+	s := Begin(tempDir)
+	s.Save("plugins/a.jar")
+	s.Finish(5)
+
+	if _, err := Restore(tempDir, "does-not-exist"); err == nil {
+		t.Error("expected error for unknown backup id")
+	}
+}
+
+func TestPrune_KeepsNewestSnapshots(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, tempDir, "plugins/a.jar", "a")
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		s := Begin(tempDir)
+		s.CreatedAt = s.CreatedAt.Add(time.Duration(i) * time.Minute)
+		s.Save("plugins/a.jar")
+		if err := s.Finish(0); err != nil {
+			t.Fatalf("finish failed: %v", err)
+		}
+		ids = append(ids, s.ID)
+	}
+
+	if err := Prune(tempDir, 2); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+
+	snapshots, _ := List(tempDir)
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	if snapshots[0].ID != ids[2] || snapshots[1].ID != ids[1] {
+		t.Errorf("expected newest snapshots %v, got %s and %s", ids[1:], snapshots[0].ID, snapshots[1].ID)
+	}
+}
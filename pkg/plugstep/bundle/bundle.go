@@ -0,0 +1,336 @@
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format is the version of the bundle layout. Open refuses bundles of a newer
+// format.
+const Format = 1
+
+const metadataName = "bundle.json"
+
+// File is a file in the bundle and where it was downloaded from. Files are
+// stored like in the download store, at <checksum type>/<checksum>.
+type File struct {
+	URL          string `json:"url"`
+	ChecksumType string `json:"checksum_type"`
+	Checksum     string `json:"checksum"`
+}
+
+func (f File) name() string {
+	return path.Join(f.ChecksumType, f.Checksum)
+}
+
+// Server is the server jar of a bundle and the [server] config it was
+// resolved for.
+type Server struct {
+	Vendor           string `json:"vendor"`
+	Project          string `json:"project"`
+	MinecraftVersion string `json:"minecraft_version"`
+	// Version is what plugstep.toml asked for, e.g. latest.
+	Version string `json:"version"`
+	// Build is the build that was resolved.
+	Build string `json:"build,omitempty"`
+	File
+}
+
+// NewServer describes the server jar build resolved for cfg.
+func NewServer(cfg config.ServerConfig, build string, f File) Server {
+	return Server{
+		Vendor:           string(cfg.Vendor),
+		Project:          cfg.Project,
+		MinecraftVersion: cfg.MinecraftVersion,
+		Version:          cfg.Version,
+		Build:            build,
+		File:             f,
+	}
+}
+
+func (s Server) String() string {
+	build := "build " + s.Version
+	if s.Version == "latest" {
+		build = "the latest build"
+	}
+	if s.Build != "" && s.Build != s.Version {
+		build += fmt.Sprintf(" (%s)", s.Build)
+	}
+	return fmt.Sprintf("%s %s %s %s", s.Vendor, s.Project, s.MinecraftVersion, build)
+}
+
+// Plugin is a plugin of a bundle and the config it was resolved for.
+type Plugin struct {
+	Source   string `json:"source"`
+	Resource string `json:"resource"`
+	// Pin is what plugstep.toml asked for: the pinned version, or the
+	// download_url of custom plugins. Empty for the latest version.
+	Pin string `json:"pin,omitempty"`
+	// Version is the version that was resolved.
+	Version string `json:"version,omitempty"`
+	File
+}
+
+// NewPlugin describes the download of version resolved for p.
+func NewPlugin(p config.PluginConfig, version string, f File) Plugin {
+	return Plugin{
+		Source:   string(p.Source),
+		Resource: *p.Resource,
+		Pin:      pin(p),
+		Version:  version,
+		File:     f,
+	}
+}
+
+func pin(p config.PluginConfig) string {
+	value := p.Version
+	if p.Source == config.PluginSourceCustom {
+		value = p.DownloadURL
+	}
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// Metadata lists what a bundle contains, the resolved URLs, versions and
+// checksums of an install. It is stored as bundle.json.
+type Metadata struct {
+	Format  int       `json:"format"`
+	Created time.Time `json:"created"`
+	Server  Server    `json:"server"`
+	Plugins []Plugin  `json:"plugins"`
+	// Includes are the remote includes of plugstep.toml, so that the config
+	// loads without fetching them.
+	Includes []File `json:"includes,omitempty"`
+}
+
+func (m Metadata) files() []File {
+	files := []File{m.Server.File}
+	for _, p := range m.Plugins {
+		files = append(files, p.File)
+	}
+	return append(files, m.Includes...)
+}
+
+// Write writes a bundle of m to path. The files are read from dir, where they
+// are laid out like in the bundle.
+func Write(path string, m Metadata, dir string) error {
+	m.Format = Format
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeArchive(tmp, m, data, dir); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeArchive(w io.Writer, m Metadata, metadata []byte, dir string) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	header := &tar.Header{Name: metadataName, Mode: 0644, Size: int64(len(metadata)), ModTime: m.Created}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tw.Write(metadata); err != nil {
+		return err
+	}
+
+	// Plugins sharing a jar share its file.
+	written := map[string]bool{}
+	for _, f := range m.files() {
+		if written[f.name()] {
+			continue
+		}
+		written[f.name()] = true
+		if err := writeFile(tw, filepath.Join(dir, filepath.FromSlash(f.name())), f.name(), m.Created); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeFile(tw *tar.Writer, src, name string, modTime time.Time) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, in)
+	return err
+}
+
+// Bundle is a bundle extracted for an install.
+type Bundle struct {
+	Metadata Metadata
+	dir      string
+}
+
+// Open extracts the bundle at path into a temporary directory and verifies
+// every file against its checksum. Close removes the directory again.
+func Open(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, err := os.MkdirTemp("", "plugstep-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{dir: dir}
+
+	if err := b.extract(f); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+	return b, nil
+}
+
+func (b *Bundle) extract(r io.Reader) error {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			return fmt.Errorf("unexpected entry %s", header.Name)
+		}
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("unexpected file %s", header.Name)
+		}
+		if err := extractFile(tr, filepath.Join(b.dir, filepath.FromSlash(header.Name))); err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(b.dir, metadataName))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s is missing, not a plugstep bundle?", metadataName)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &b.Metadata); err != nil {
+		return fmt.Errorf("invalid %s: %w", metadataName, err)
+	}
+	if b.Metadata.Format > Format {
+		return fmt.Errorf("bundle format %d is newer than this plugstep supports, upgrade plugstep", b.Metadata.Format)
+	}
+
+	for _, f := range b.Metadata.files() {
+		if err := utils.VerifyFileChecksum(b.Path(f.ChecksumType, f.Checksum), f.ChecksumType, f.Checksum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(r io.Reader, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Path returns where the extracted file with the checksum is.
+func (b *Bundle) Path(checksumType, checksum string) string {
+	return filepath.Join(b.dir, checksumType, checksum)
+}
+
+// FindServer returns the server jar if the bundle was made for cfg.
+func (b *Bundle) FindServer(cfg config.ServerConfig) (Server, error) {
+	s := b.Metadata.Server
+	if want := NewServer(cfg, s.Build, s.File); want != s {
+		want.Build = ""
+		return Server{}, exitcode.Errorf(exitcode.Config, "the bundle has %s but plugstep.toml wants %s, vendor it again", s, want)
+	}
+	return s, nil
+}
+
+// FindPlugin returns the bundled download of p, resolved for the same version
+// pin.
+func (b *Bundle) FindPlugin(p config.PluginConfig) (Plugin, error) {
+	for _, bundled := range b.Metadata.Plugins {
+		if bundled.Source != string(p.Source) || !strings.EqualFold(bundled.Resource, *p.Resource) {
+			continue
+		}
+		if bundled.Pin != pin(p) {
+			return Plugin{}, exitcode.Errorf(exitcode.Config, "the bundle has %s but plugstep.toml wants %s, vendor it again", describePin(bundled.Pin), describePin(pin(p)))
+		}
+		return bundled, nil
+	}
+	return Plugin{}, exitcode.Errorf(exitcode.Config, "not in the bundle, vendor it again")
+}
+
+func describePin(pin string) string {
+	if pin == "" {
+		return "the latest version"
+	}
+	return pin
+}
+
+// Close removes the extracted files.
+func (b *Bundle) Close() error {
+	return os.RemoveAll(b.dir)
+}
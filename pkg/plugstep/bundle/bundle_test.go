@@ -0,0 +1,136 @@
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"github.com/klauspost/compress/zstd"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// stage writes content into dir like Write expects it and returns its File.
+func stage(t *testing.T, dir, content string) File {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	f := File{URL: "https://example.com/" + content, ChecksumType: "sha256", Checksum: hex.EncodeToString(sum[:])}
+	path := filepath.Join(dir, "sha256", f.Checksum)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+var testServer = config.ServerConfig{Vendor: "papermc", Project: "paper", MinecraftVersion: "1.21.8", Version: "latest"}
+
+func TestWriteOpen_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	luckperms := config.PluginConfig{Source: "modrinth", Resource: strPtr("LuckPerms"), Version: strPtr("v5.4")}
+	m := Metadata{
+		Created: time.Now().UTC(),
+		Server:  NewServer(testServer, "130", stage(t, dir, "server")),
+		Plugins: []Plugin{NewPlugin(luckperms, "v5.4.1", stage(t, dir, "luckperms"))},
+	}
+	path := filepath.Join(t.TempDir(), "server.tar.zst")
+
+	if err := Write(path, m, dir); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer b.Close()
+
+	server, err := b.FindServer(testServer)
+	if err != nil {
+		t.Errorf("expected the server to match, got %v", err)
+	}
+	if server.Version != "latest" || server.Build != "130" {
+		t.Errorf("expected the configured version and the resolved build, got %q and %q", server.Version, server.Build)
+	}
+	plugin, err := b.FindPlugin(config.PluginConfig{Source: "modrinth", Resource: strPtr("luckperms"), Version: strPtr("v5.4")})
+	if err != nil {
+		t.Fatalf("expected the plugin to be found, got %v", err)
+	}
+	if plugin.Version != "v5.4.1" {
+		t.Errorf("expected the resolved version, got %q", plugin.Version)
+	}
+	if content, _ := os.ReadFile(b.Path(plugin.ChecksumType, plugin.Checksum)); string(content) != "luckperms" {
+		t.Errorf("expected the plugin jar, got %q", content)
+	}
+}
+
+func TestFind_ChangedConfigIsConfigError(t *testing.T) {
+	dir := t.TempDir()
+	luckperms := config.PluginConfig{Source: "modrinth", Resource: strPtr("luckperms")}
+	b := &Bundle{Metadata: Metadata{
+		Server:  NewServer(testServer, "130", stage(t, dir, "server")),
+		Plugins: []Plugin{NewPlugin(luckperms, "v5.4.1", stage(t, dir, "luckperms"))},
+	}}
+
+	newer := testServer
+	newer.MinecraftVersion = "1.21.9"
+	pinned := luckperms
+	pinned.Version = strPtr("v5.3")
+	missing := config.PluginConfig{Source: "modrinth", Resource: strPtr("essentialsx")}
+
+	_, serverErr := b.FindServer(newer)
+	_, pinnedErr := b.FindPlugin(pinned)
+	_, missingErr := b.FindPlugin(missing)
+
+	for name, err := range map[string]error{"server": serverErr, "pinned": pinnedErr, "missing": missingErr} {
+		if code := exitcode.Of(err); code != exitcode.Config {
+			t.Errorf("%s: expected config exit code, got %d (%v)", name, code, err)
+		}
+	}
+}
+
+func TestOpen_TamperedFileIsChecksumError(t *testing.T) {
+	dir := t.TempDir()
+	f := stage(t, dir, "server")
+	os.WriteFile(filepath.Join(dir, "sha256", f.Checksum), []byte("tampered"), 0644)
+	path := filepath.Join(t.TempDir(), "server.tar.zst")
+	if err := Write(path, Metadata{Server: NewServer(testServer, "130", f)}, dir); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	_, err := Open(path)
+
+	if code := exitcode.Of(err); code != exitcode.Checksum {
+		t.Errorf("expected checksum exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestOpen_RejectsPathsOutsideTheBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evil.tar.zst")
+	out, _ := os.Create(path)
+	zw, _ := zstd.NewWriter(out)
+	tw := tar.NewWriter(zw)
+	tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Size: 4})
+	tw.Write([]byte("evil"))
+	tw.Close()
+	zw.Close()
+	out.Close()
+
+	_, err := Open(path)
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, statErr := os.Stat(filepath.Join(os.TempDir(), "evil")); statErr == nil {
+		t.Error("expected nothing to be written outside the bundle")
+	}
+}
@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"github.com/charmbracelet/log"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+const defaultStoreMaxAge = "30d"
+
+func newCacheCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and clean the metadata cache and the download store",
+		Long: "Plugstep caches API responses and file hashes per server in\n" +
+			".plugstep/cache.db, and keeps downloads with a known checksum in a store\n" +
+			"shared by every server of the user, $" + utils.StoreEnv + " or\n" +
+			"$XDG_CACHE_HOME/plugstep/store. stats, ls, prune and clear work on the cache\n" +
+			"of --dir, gc on the store.",
+		Args: noSubcommand,
+	}
+	cmd.AddCommand(
+		newCacheStatsCommand(opts),
+		newCacheLsCommand(opts),
+		newCachePruneCommand(opts),
+		newCacheClearCommand(opts),
+		newCacheGCCommand(opts),
+	)
+	return cmd
+}
+
+func newCacheStatsCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show entries, size and hit ratio per cache namespace",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !openCache(opts.serverDirectory) {
+				return nil
+			}
+			return CacheStatsCommand()
+		},
+	}
+}
+
+func newCacheLsCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:     "ls [namespace]",
+		Aliases: []string{"list"},
+		Short:   "List the cached entries, of one namespace or all",
+		Example: "  plugstep cache ls\n" +
+			"  plugstep cache ls plugins",
+		Args: usageArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !openCache(opts.serverDirectory) {
+				return nil
+			}
+			namespace := ""
+			if len(args) == 1 {
+				namespace = args[0]
+			}
+			return CacheLsCommand(namespace)
+		},
+	}
+}
+
+func newCachePruneCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "prune",
+		Short: "Delete expired entries and hashes of files that changed or no longer exist",
+		Long: "Delete the expired entries and the file hashes of files that were deleted or\n" +
+			"changed since they were hashed. Expired API responses with an ETag or\n" +
+			"Last-Modified are kept: the next install revalidates them instead of\n" +
+			"fetching them again, and --offline installs still use them.",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !openCache(opts.serverDirectory) {
+				return nil
+			}
+			return CachePruneCommand()
+		},
+	}
+}
+
+func newCacheClearCommand(opts *globalOptions) *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "clear [namespace | --all]",
+		Short: "Delete every entry of a cache namespace, or of all of them",
+		Example: "  plugstep cache clear plugins\n" +
+			"  plugstep cache clear --all",
+		Args: usageArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return usageError(fmt.Errorf("pass either a namespace or --all"))
+			}
+			if !openCache(opts.serverDirectory) {
+				return nil
+			}
+			namespace := ""
+			if len(args) == 1 {
+				namespace = args[0]
+			}
+			return CacheClearCommand(namespace)
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "clear every namespace")
+
+	return cmd
+}
+
+func newCacheGCCommand(opts *globalOptions) *cobra.Command {
+	var maxAge, maxSize string
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Evict old files from the download store",
+		Long: "Remove the store files no server installed for longer than --max-age, then\n" +
+			"the least recently used ones until the store fits in --max-size. Servers\n" +
+			"keep the files they already installed.",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := parseAge(maxAge)
+			if err != nil {
+				return usageError(fmt.Errorf("--max-age: %w", err))
+			}
+			var size uint64
+			if maxSize != "" {
+				if size, err = humanize.ParseBytes(maxSize); err != nil {
+					return usageError(fmt.Errorf("--max-size: %w", err))
+				}
+			}
+			return CacheGCCommand(age, int64(size))
+		},
+	}
+	cmd.Flags().StringVar(&maxAge, "max-age", defaultStoreMaxAge, "evict files unused for longer, e.g. 7d or 12h (0 keeps them)")
+	cmd.Flags().StringVar(&maxSize, "max-size", "", "evict the least recently used files beyond this size, e.g. 5GB")
+
+	return cmd
+}
+
+func CacheGCCommand(maxAge time.Duration, maxSize int64) error {
+	if utils.StoreDirectory == "" {
+		return exitcode.Errorf(exitcode.Config, "no download store, set $%s", utils.StoreEnv)
+	}
+
+	removed, kept, err := utils.GCStore(maxAge, maxSize)
+	if err != nil {
+		return fmt.Errorf("failed to clean the download store: %w", err)
+	}
+
+	log.Info("Cleaned download store",
+		"dir", utils.StoreDirectory,
+		"removed", len(removed),
+		"freed", humanize.Bytes(uint64(storeSize(removed))),
+		"kept", len(kept),
+		"size", humanize.Bytes(uint64(storeSize(kept))),
+	)
+	return nil
+}
+
+// openCache opens the cache of a server directory, reporting false when it
+// has none yet.
+func openCache(serverDirectory string) bool {
+	if _, err := os.Stat(utils.CachePath(serverDirectory)); err != nil {
+		log.Info("No cache yet, it is created by the first install", "dir", serverDirectory)
+		return false
+	}
+	if err := utils.InitCacheDB(serverDirectory); err != nil {
+		log.Warn("Failed to open the cache", "err", err)
+		return false
+	}
+	return true
+}
+
+func CacheStatsCommand() error {
+	stats, err := utils.CacheStats()
+	if err != nil {
+		return fmt.Errorf("failed to read the cache: %w", err)
+	}
+
+	fmt.Println(headerStyle.Render(fmt.Sprintf("CACHE (%d namespaces)", len(stats))))
+	var entries int
+	var size int64
+	for _, s := range stats {
+		entries += s.Entries
+		size += s.Size
+		ratio := "-"
+		if s.Hits+s.Misses > 0 {
+			ratio = fmt.Sprintf("%.0f%%", s.HitRatio()*100)
+		}
+		fmt.Printf("  %s %s %s %s %s\n",
+			arrowStyle.Render("→"),
+			nameStyle.Width(12).Render(s.Namespace),
+			versionStyle.Width(14).Render(fmt.Sprintf("%d entries", s.Entries)),
+			descStyle.Width(12).Render(humanize.Bytes(uint64(s.Size))),
+			descStyle.Render(fmt.Sprintf("%d expired, hit ratio %s (%d hits, %d misses)", s.Expired, ratio, s.Hits, s.Misses)),
+		)
+	}
+
+	if utils.StoreDirectory != "" {
+		if files, err := utils.StoreEntries(); err == nil {
+			fmt.Println(headerStyle.Render("DOWNLOAD STORE"))
+			fmt.Printf("  %s %s %s %s\n",
+				arrowStyle.Render("→"),
+				versionStyle.Width(14).Render(fmt.Sprintf("%d files", len(files))),
+				descStyle.Width(12).Render(humanize.Bytes(uint64(storeSize(files)))),
+				descStyle.Render(utils.StoreDirectory),
+			)
+		}
+	}
+
+	fmt.Println()
+	log.Info("Cache stats.", "entries", entries, "size", humanize.Bytes(uint64(size)))
+	return nil
+}
+
+func CacheLsCommand(namespace string) error {
+	entries, err := utils.CacheEntries(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to read the cache: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Println(descStyle.Render("No cached entries"))
+		return nil
+	}
+
+	current := ""
+	for _, e := range entries {
+		if e.Namespace != current {
+			current = e.Namespace
+			fmt.Println(headerStyle.Render(strings.ToUpper(current)))
+		}
+		state := versionStyle.Render("permanent")
+		switch {
+		case e.Expired() && e.Revalidatable():
+			state = planDownloadStyle.Render("expired, revalidates")
+		case e.Expired():
+			state = planFailedStyle.Render("expired")
+		case e.TTL > 0:
+			state = versionStyle.Render("fresh for " + time.Until(e.Stored.Add(e.TTL)).Round(time.Second).String())
+		}
+		fmt.Printf("  %s %s %s %s %s\n",
+			arrowStyle.Render("→"),
+			nameStyle.Render(e.Key),
+			descStyle.Render(humanize.Bytes(uint64(e.Size))),
+			descStyle.Render("stored "+humanize.Time(e.Stored)),
+			state,
+		)
+	}
+	return nil
+}
+
+func CachePruneCommand() error {
+	expired, stale, err := utils.PruneCache()
+	if err != nil {
+		return fmt.Errorf("failed to prune the cache: %w", err)
+	}
+	log.Info("Pruned cache", "expired", expired, "stale-file-hashes", stale)
+	return nil
+}
+
+func CacheClearCommand(namespace string) error {
+	cleared, err := utils.ClearCache(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to clear the cache: %w", err)
+	}
+	if namespace == "" {
+		namespace = "all"
+	}
+	log.Info("Cleared cache", "namespace", namespace, "entries", cleared)
+	return nil
+}
+
+func storeSize(entries []utils.StoreEntry) int64 {
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	return size
+}
+
+// parseAge parses a duration that may also be given in days, e.g. "30d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(s)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return age, nil
+}
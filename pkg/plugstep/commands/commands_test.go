@@ -1,9 +1,28 @@
 package commands
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/bundle"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/workspace"
+	"github.com/spf13/cobra"
 )
 
 // =============================================================================
@@ -220,3 +239,813 @@ func TestGetSourceBadge_Unknown(t *testing.T) {
 		t.Error("expected non-empty badge for unknown source (should uppercase it)")
 	}
 }
+
+// =============================================================================
+// applyCompatRows Tests
+// =============================================================================
+
+func TestApplyCompatRows_BumpsServerAndPins(t *testing.T) {
+	luckperms := "luckperms"
+	chunky := "chunky"
+	oldVersion := "1.0.0"
+	cfg := &config.PlugstepConfig{
+		Server: config.ServerConfig{
+			Vendor:           config.ServerJarVendorPaperMC,
+			Project:          "paper",
+			MinecraftVersion: "1.21.4",
+			Version:          "100",
+		},
+		Plugins: []config.PluginConfig{
+			{Source: config.PluginSourceModrinth, Resource: &luckperms, Version: &oldVersion},
+			{Source: config.PluginSourceModrinth, Resource: &chunky},
+		},
+	}
+	rows := []compatRow{
+		{status: compatNeedsUpdate, target: "42"},
+		{status: compatNeedsUpdate, target: "2.0.0"},
+		{status: compatCompatible, target: "(latest)"},
+	}
+
+	applyCompatRows(cfg, rows, "1.21.8")
+
+	if cfg.Server.MinecraftVersion != "1.21.8" {
+		t.Errorf("expected minecraft_version %q, got %q", "1.21.8", cfg.Server.MinecraftVersion)
+	}
+	if cfg.Server.Version != "42" {
+		t.Errorf("expected server version %q, got %q", "42", cfg.Server.Version)
+	}
+	if cfg.Plugins[0].Version == nil || *cfg.Plugins[0].Version != "2.0.0" {
+		t.Errorf("expected luckperms pinned to %q, got %v", "2.0.0", cfg.Plugins[0].Version)
+	}
+	if cfg.Plugins[1].Version != nil {
+		t.Errorf("expected chunky to stay unpinned, got %q", *cfg.Plugins[1].Version)
+	}
+}
+
+// =============================================================================
+// Command tree Tests
+// =============================================================================
+
+func executeRoot(t *testing.T, args ...string) error {
+	t.Helper()
+	t.Setenv(utils.StoreEnv, t.TempDir())
+	t.Cleanup(func() {
+		utils.CloseCache()
+		utils.StoreDirectory = ""
+		utils.Offline = false
+	})
+	root := NewRootCommand(func() {})
+	root.SetArgs(args)
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	return root.Execute()
+}
+
+func TestRootCommand_InterleavedFlagsAndExtraArgument(t *testing.T) {
+	dir := t.TempDir()
+	writeTestConfig(t, dir)
+
+	err := executeRoot(t, "upgrade-mc", "1.21.8", "--write", "--dir", dir, "extra")
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code for extra argument, got %d (%v)", code, err)
+	}
+}
+
+func TestUpgradeMinecraft_WriteRefusesUncheckedComponentsWithoutForce(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/paper":
+			w.Write([]byte(`{"versions": {"1.21": ["1.21.9", "1.21.8"]}}`))
+		case "/v3/projects/paper/versions/1.21.9/builds":
+			w.Write([]byte(`{"builds": [{"build": 1}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	data := fmt.Sprintf(`[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+[vendors.papermc]
+base_url = "%s"
+
+[[plugins]]
+source = "custom"
+resource = "unchecked"
+download_url = "https://example.com/unchecked.jar"
+`, api.URL)
+	path := filepath.Join(dir, "plugstep.toml")
+	os.WriteFile(path, []byte(data), 0644)
+
+	if err := executeRoot(t, "upgrade-mc", "1.21.9", "--write", "--dir", dir); err == nil {
+		t.Error("expected --write to refuse unchecked components")
+	}
+	if content, _ := os.ReadFile(path); string(content) != data {
+		t.Errorf("expected plugstep.toml to be unchanged, got\n%s", content)
+	}
+
+	if err := executeRoot(t, "upgrade-mc", "1.21.9", "--write", "--force", "--dir", dir); err != nil {
+		t.Fatalf("expected --force to write, got %v", err)
+	}
+	if content, _ := os.ReadFile(path); !strings.Contains(string(content), `minecraft_version = "1.21.9"`) {
+		t.Errorf("expected minecraft_version to be updated, got\n%s", content)
+	}
+}
+
+func TestRootCommand_UnknownCommandIsUsageError(t *testing.T) {
+	for _, args := range [][]string{{"bogus"}, {"plugin", "bogus"}} {
+		err := executeRoot(t, args...)
+
+		if code := exitcode.Of(err); code != exitcode.Usage {
+			t.Errorf("%v: expected usage exit code, got %d (%v)", args, code, err)
+		}
+	}
+}
+
+func TestRootCommand_UnknownFlagIsUsageError(t *testing.T) {
+	err := executeRoot(t, "install", "--bogus")
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestRootCommand_CompletesConfiguredPlugins(t *testing.T) {
+	dir := t.TempDir()
+	writeTestConfig(t, dir)
+
+	var out bytes.Buffer
+	root := NewRootCommand(func() {})
+	root.SetArgs([]string{cobra.ShellCompNoDescRequestCmd, "--dir", dir, "plugin", "remove", "luck"})
+	root.SetOut(&out)
+	if err := root.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || lines[0] != "luckperms" {
+		t.Errorf("expected luckperms completion, got %q", out.String())
+	}
+}
+
+func TestNormalizeLegacyFlags(t *testing.T) {
+	got := NormalizeLegacyFlags([]string{"-dir", "srv", "-flush-cache", "-d", "plugin", "search", "--", "-dir"})
+	want := []string{"--dir", "srv", "--flush-cache", "-d", "plugin", "search", "--", "-dir"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := NormalizeLegacyFlags([]string{"-throttle-network=64"}); got[0] != "--throttle-network=64" {
+		t.Errorf("expected --throttle-network=64, got %v", got)
+	}
+}
+
+func TestUpgradeMinecraft_WriteOnlyRewritesChangedValues(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/paper":
+			w.Write([]byte(`{"versions": {"1.21": ["1.21.9", "1.21.8"]}}`))
+		case "/v3/projects/paper/versions/1.21.9/builds":
+			w.Write([]byte(`{"builds": [{"build": 1}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+	t.Setenv("PAPER_BUILD", "100")
+
+	for name, test := range map[string]struct {
+		server string
+		want   string
+	}{
+		"unchanged":    {server: "minecraft_version = '1.21.9'\nversion = 'latest'\n"},
+		"interpolated": {server: "minecraft_version = \"1.21.8\"\nversion = \"${PAPER_BUILD}\"\n", want: "minecraft_version = \"1.21.9\"\nversion = \"${PAPER_BUILD}\"\n"},
+	} {
+		dir := t.TempDir()
+		data := fmt.Sprintf("[server]\nvendor = \"papermc\"\nproject = \"paper\"\n%s\n[vendors.papermc]\nbase_url = %q\n", test.server, api.URL)
+		path := filepath.Join(dir, "plugstep.toml")
+		os.WriteFile(path, []byte(data), 0644)
+
+		if err := executeRoot(t, "upgrade-mc", "1.21.9", "--write", "--dir", dir); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		want := data
+		if test.want != "" {
+			want = strings.Replace(data, test.server, test.want, 1)
+		}
+		if content, _ := os.ReadFile(path); string(content) != want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, want, content)
+		}
+	}
+}
+
+func writeTestConfig(t *testing.T, dir string) {
+	t.Helper()
+	data := `[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+[[plugins]]
+source = "modrinth"
+resource = "luckperms"
+`
+	if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+// =============================================================================
+// Exit code Tests
+// =============================================================================
+
+func TestPluginList_MissingConfigIsConfigError(t *testing.T) {
+	err := pluginList(t.TempDir(), false)
+
+	if code := exitcode.Of(err); code != exitcode.Config {
+		t.Errorf("expected config exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestPluginRemove_MissingNameIsUsageError(t *testing.T) {
+	err := executeRoot(t, "plugin", "remove", "--dir", t.TempDir())
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code, got %d (%v)", code, err)
+	}
+}
+
+// =============================================================================
+// InitCommand Tests
+// =============================================================================
+
+func TestInitCommand_RefusesExistingConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeTestConfig(t, dir)
+
+	err := InitCommand(dir, InitOptions{Template: "paper"})
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestInitCommand_UnknownTemplate(t *testing.T) {
+	err := InitCommand(t.TempDir(), InitOptions{Template: "bogus"})
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestInstall_MissingConfigWithoutTerminalFails(t *testing.T) {
+	err := executeRoot(t, "install", "--dir", t.TempDir())
+
+	if code := exitcode.Of(err); code != exitcode.Config {
+		t.Errorf("expected config exit code instead of the setup wizard, got %d (%v)", code, err)
+	}
+}
+
+// =============================================================================
+// Config editing Tests
+// =============================================================================
+
+func TestPluginRemove_KeepsCommentsAndOtherPlugins(t *testing.T) {
+	dir := t.TempDir()
+	data := `# Production server
+[server]
+vendor = "papermc" # the only vendor
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+# Permissions
+[[plugins]]
+source = "modrinth"
+resource = "luckperms"
+
+# Protocol support
+[[plugins]]
+source = "paper-hangar"
+resource = "ViaVersion"
+`
+	configPath := filepath.Join(dir, "plugstep.toml")
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	if err := pluginRemove("luckperms", false, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	want := strings.Replace(data, "# Permissions\n[[plugins]]\nsource = \"modrinth\"\nresource = \"luckperms\"\n\n", "", 1)
+	if string(got) != want {
+		t.Errorf("unexpected config:\n%s", got)
+	}
+}
+
+func TestPluginKeyValues_SkipsUnsetFields(t *testing.T) {
+	resource := "luckperms"
+	values := pluginKeyValues(config.PluginConfig{Source: config.PluginSourceModrinth, Resource: &resource})
+
+	if len(values) != 2 || values[0].Key != "source" || values[1].Key != "resource" {
+		t.Errorf("expected source and resource, got %+v", values)
+	}
+}
+
+// =============================================================================
+// validate Tests
+// =============================================================================
+
+func TestValidateCommand_ValidConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeTestConfig(t, dir)
+
+	if err := executeRoot(t, "validate", "--dir", dir); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+func TestValidateCommand_InvalidConfigIsConfigError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugstep.toml")
+	if err := os.WriteFile(path, []byte("[server]\nvendor = \"paper\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	err := executeRoot(t, "validate", path)
+
+	if code := exitcode.Of(err); code != exitcode.Config {
+		t.Errorf("expected config exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestPluginList_InvalidConfigIsConfigError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte("[servr]\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	err := pluginList(dir, false)
+
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) || exitcode.Of(err) != exitcode.Config {
+		t.Errorf("expected validation error with config exit code, got %v", err)
+	}
+}
+
+// =============================================================================
+// lint Tests
+// =============================================================================
+
+func TestLintCommand_ErrorsAreConfigError(t *testing.T) {
+	dir := t.TempDir()
+	data := `[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "130"
+
+[[plugins]]
+source = "custom"
+resource = "mine"
+download_url = "https://example.com/mine.jar"
+
+[[plugins]]
+source = "custom"
+resource = "mine"
+download_url = "https://example.com/mine-2.jar"
+`
+	if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	err := executeRoot(t, "lint", "--dir", dir, "--json")
+
+	if code := exitcode.Of(err); code != exitcode.Config {
+		t.Errorf("expected config exit code for duplicate plugins, got %d (%v)", code, err)
+	}
+}
+
+func TestLintCommand_WarningsPass(t *testing.T) {
+	dir := t.TempDir()
+	data := `[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+[[plugins]]
+source = "custom"
+resource = "mine"
+download_url = "https://example.com/mine.jar"
+`
+	if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	if err := executeRoot(t, "lint", "--dir", dir); err != nil {
+		t.Errorf("expected warnings not to fail lint, got %v", err)
+	}
+}
+
+// =============================================================================
+// schema Tests
+// =============================================================================
+
+func TestSchemaCommand_WritesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugstep.schema.json")
+
+	if err := executeRoot(t, "schema", "-o", path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if !strings.Contains(string(data), `"$schema": "http://json-schema.org/draft-07/schema#"`) {
+		t.Errorf("expected a JSON Schema, got %s", data)
+	}
+}
+
+// =============================================================================
+// Profile Tests
+// =============================================================================
+
+func TestPluginRemove_ProfilePlugin(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "plugstep.toml")
+	data := `[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+[[plugins]]
+source = "modrinth"
+resource = "luckperms"
+
+[[profiles.dev.plugins]]
+source = "modrinth"
+resource = "chunky"
+
+[[profiles.dev.plugins]]
+source = "modrinth"
+resource = "spark"
+`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	config.SelectProfile("dev")
+	defer config.SelectProfile("")
+
+	if err := pluginRemove("spark", false, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	want := strings.TrimSuffix(data, "\n[[profiles.dev.plugins]]\nsource = \"modrinth\"\nresource = \"spark\"\n")
+	if string(got) != want {
+		t.Errorf("expected only the profile's spark entry to be removed, got:\n%s", got)
+	}
+}
+
+func TestRootCommand_UnknownProfileIsConfigError(t *testing.T) {
+	dir := t.TempDir()
+	writeTestConfig(t, dir)
+	defer config.SelectProfile("")
+
+	err := executeRoot(t, "plugin", "list", "--dir", dir, "--profile", "staging")
+
+	if code := exitcode.Of(err); code != exitcode.Config {
+		t.Errorf("expected config exit code for unknown profile, got %d (%v)", code, err)
+	}
+}
+
+func TestPluginRemove_IncludedPluginIsUsageError(t *testing.T) {
+	dir := t.TempDir()
+	data := `include = ["core.toml"]
+
+[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+`
+	if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "core.toml"), []byte("[[plugins]]\nsource = \"modrinth\"\nresource = \"luckperms\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write include: %v", err)
+	}
+
+	err := pluginRemove("luckperms", false, dir)
+
+	if code := exitcode.Of(err); code != exitcode.Usage || !strings.Contains(err.Error(), "core.toml") {
+		t.Errorf("expected usage error pointing at core.toml, got %d (%v)", code, err)
+	}
+}
+
+// =============================================================================
+// Workspace Tests
+// =============================================================================
+
+func TestInstall_AllOutsideWorkspaceIsConfigError(t *testing.T) {
+	err := executeRoot(t, "install", "--all", "--dir", t.TempDir())
+
+	if code := exitcode.Of(err); code != exitcode.Config {
+		t.Errorf("expected config exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestPluginList_AllReportsFailingMembersAsPartial(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, workspace.FileName), []byte(`members = ["lobby", "survival"]`), 0644)
+	os.MkdirAll(filepath.Join(root, "lobby"), 0755)
+	os.MkdirAll(filepath.Join(root, "survival"), 0755)
+	writeTestConfig(t, filepath.Join(root, "lobby"))
+
+	err := executeRoot(t, "plugin", "list", "--all", "--dir", filepath.Join(root, "lobby"))
+
+	if code := exitcode.Of(err); code != exitcode.Partial {
+		t.Errorf("expected partial exit code, got %d (%v)", code, err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "survival") {
+		t.Errorf("expected the error to name the failing member, got %v", err)
+	}
+}
+
+func TestWorkspaceError(t *testing.T) {
+	failed := errors.New("boom")
+
+	if err := workspaceError([]workspace.Result{{Member: "a"}, {Member: "b"}}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err := workspaceError([]workspace.Result{{Member: "a", Err: exitcode.Wrap(exitcode.Network, failed)}, {Member: "b", Err: failed}})
+	if code := exitcode.Of(err); code != exitcode.Network {
+		t.Errorf("expected the code of the first failure when every member failed, got %d", code)
+	}
+}
+
+// =============================================================================
+// Cache Tests
+// =============================================================================
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"0":   0,
+	}
+	for input, expected := range tests {
+		if got, err := parseAge(input); err != nil || got != expected {
+			t.Errorf("parseAge(%q) = %s, %v, expected %s", input, got, err, expected)
+		}
+	}
+	if _, err := parseAge("soon"); err == nil {
+		t.Error("expected an error for an invalid age")
+	}
+}
+
+func TestCacheGC_InvalidSizeIsUsageError(t *testing.T) {
+	err := executeRoot(t, "cache", "gc", "--max-size", "lots")
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestFetch_OfflineIsUsageError(t *testing.T) {
+	err := executeRoot(t, "--offline", "fetch")
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestVendor_InstallFromBundleWithoutNetwork(t *testing.T) {
+	jar := []byte("server jar")
+	sum := sha256.Sum256(jar)
+	var requests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/v3/projects/paper/versions/1.21.8/builds/latest":
+			fmt.Fprintf(w, `{"id": 130, "downloads": {"server:default": {"url": "http://%s/server.jar", "checksums": {"sha256": "%x"}}}}`, r.Host, sum)
+		case "/server.jar":
+			w.Write(jar)
+		case "/plugin.jar":
+			w.Write([]byte("plugin jar"))
+		case "/shared.toml":
+			fmt.Fprintf(w, "[[plugins]]\nsource = \"custom\"\nresource = \"shared\"\ndownload_url = \"http://%s/shared.jar\"\n", r.Host)
+		case "/shared.jar":
+			w.Write([]byte("shared jar"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	shared := sha256.Sum256([]byte(fmt.Sprintf("[[plugins]]\nsource = \"custom\"\nresource = \"shared\"\ndownload_url = \"%s/shared.jar\"\n", api.URL)))
+	data := fmt.Sprintf(`include = ["%[1]s/shared.toml#sha256=%[2]x"]
+
+[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+[vendors.papermc]
+base_url = "%[1]s"
+
+[[plugins]]
+source = "custom"
+resource = "unverified"
+download_url = "%[1]s/plugin.jar"
+`, api.URL, shared)
+	online, offline := t.TempDir(), t.TempDir()
+	for _, dir := range []string{online, offline} {
+		if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(t.TempDir(), "server.tar.zst")
+
+	if err := executeRoot(t, "vendor", out, "--dir", online); err != nil {
+		t.Fatalf("vendor failed: %v", err)
+	}
+	vendored := requests.Load()
+	b, err := bundle.Open(out)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if server := b.Metadata.Server; server.Version != "latest" || server.Build != "130" {
+		t.Errorf("expected the bundle to record the resolved build, got %s", server)
+	}
+	if len(b.Metadata.Includes) != 1 {
+		t.Errorf("expected the bundle to carry the remote include, got %+v", b.Metadata.Includes)
+	}
+	b.Close()
+	// The offline host starts with an empty cache.
+	utils.CloseCache()
+
+	err = executeRoot(t, "install", "--from-bundle", out, "--output", "plain", "--dir", offline)
+
+	if err != nil {
+		t.Fatalf("install from bundle failed: %v", err)
+	}
+	if requests.Load() != vendored {
+		t.Errorf("expected no requests from the install, got %d", requests.Load()-vendored)
+	}
+	for file, want := range map[string]string{"server.jar": "server jar", "plugins/unverified.jar": "plugin jar", "plugins/shared.jar": "shared jar"} {
+		if content, _ := os.ReadFile(filepath.Join(offline, file)); string(content) != want {
+			t.Errorf("expected %s to be installed from the bundle, got %q", file, content)
+		}
+	}
+}
+
+func TestInstall_ChecksumMismatchOutranksPartial(t *testing.T) {
+	jar := []byte("server jar")
+	sum := sha256.Sum256(jar)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/paper/versions/1.21.8/builds/latest":
+			fmt.Fprintf(w, `{"id": 130, "downloads": {"server:default": {"url": "http://%s/server.jar", "checksums": {"sha256": "%x"}}}}`, r.Host, sum)
+		case "/server.jar":
+			w.Write(jar)
+		case "/plugin.jar":
+			w.Write([]byte("plugin jar"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	data := fmt.Sprintf(`[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+[vendors.papermc]
+base_url = "%[1]s"
+
+[[plugins]]
+source = "custom"
+resource = "fine"
+download_url = "%[1]s/plugin.jar"
+
+[[plugins]]
+source = "custom"
+resource = "tampered"
+download_url = "%[1]s/plugin.jar"
+checksum = "sha256:%[2]s"
+`, api.URL, strings.Repeat("0", 64))
+	if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := executeRoot(t, "install", "--output", "plain", "--dir", dir)
+
+	if code := exitcode.Of(err); code != exitcode.Checksum {
+		t.Errorf("expected checksum exit code, got %d (%v)", code, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "plugins", "fine.jar")); string(content) != "plugin jar" {
+		t.Errorf("expected the other plugin to be installed, got %q", content)
+	}
+}
+
+func TestInstall_FromBundleWithAllIsUsageError(t *testing.T) {
+	err := executeRoot(t, "install", "--all", "--from-bundle", "server.tar.zst")
+
+	if code := exitcode.Of(err); code != exitcode.Usage {
+		t.Errorf("expected usage exit code, got %d (%v)", code, err)
+	}
+}
+
+func TestCacheClear_NeedsNamespaceOrAll(t *testing.T) {
+	for _, args := range [][]string{{"cache", "clear"}, {"cache", "clear", "plugins", "--all"}} {
+		err := executeRoot(t, args...)
+
+		if code := exitcode.Of(err); code != exitcode.Usage {
+			t.Errorf("%v: expected usage exit code, got %d (%v)", args, code, err)
+		}
+	}
+}
+
+func TestInstall_AllKeepsMemberCredentialsApart(t *testing.T) {
+	jar := []byte("server jar")
+	sum := sha256.Sum256(jar)
+	var mu sync.Mutex
+	tokens := map[string]string{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/paper/versions/1.21.8/builds/latest":
+			// Gives every member time to configure before the plugins download.
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprintf(w, `{"downloads": {"server:default": {"url": "http://%s/server.jar", "checksums": {"sha256": "%x"}}}}`, r.Host, sum)
+		case "/server.jar":
+			w.Write(jar)
+		default:
+			mu.Lock()
+			tokens[r.URL.Path] = r.Header.Get("Authorization")
+			mu.Unlock()
+			w.Write([]byte("plugin jar"))
+		}
+	}))
+	defer api.Close()
+
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, workspace.FileName), []byte(`members = ["lobby", "survival"]`), 0644)
+	for _, member := range []string{"lobby", "survival"} {
+		dir := filepath.Join(root, member)
+		os.MkdirAll(dir, 0755)
+		data := fmt.Sprintf(`[server]
+vendor = "papermc"
+project = "paper"
+minecraft_version = "1.21.8"
+version = "latest"
+
+[vendors.papermc]
+base_url = "%[1]s"
+
+[sources.custom]
+token = "%[2]s-token"
+
+[[plugins]]
+source = "custom"
+resource = "%[2]s"
+download_url = "%[1]s/%[2]s.jar"
+`, api.URL, member)
+		if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := executeRoot(t, "install", "--all", "--output", "plain", "--dir", root); err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+
+	for _, member := range []string{"lobby", "survival"} {
+		if got, want := tokens["/"+member+".jar"], "Bearer "+member+"-token"; got != want {
+			t.Errorf("%s: expected %q, got %q", member, want, got)
+		}
+	}
+}
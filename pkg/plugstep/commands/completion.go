@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/backup"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+)
+
+var specSources = []string{"modrinth:", "hangar:", "custom:"}
+
+// completeConfiguredPlugins completes the resources of the plugins in
+// plugstep.toml.
+func (opts *globalOptions) completeConfiguredPlugins(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	cfg, _, err := loadConfig(opts.serverDirectory)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var names []string
+	for _, p := range cfg.Plugins {
+		if p.Resource != nil && strings.HasPrefix(*p.Resource, toComplete) {
+			names = append(names, *p.Resource+"\t"+string(p.Source))
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeBackups completes the ids of the backups in the server directory.
+func (opts *globalOptions) completeBackups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	snapshots, err := backup.List(opts.serverDirectory)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var ids []string
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.ID, toComplete) {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// completeProfiles completes the profiles defined in plugstep.toml.
+func (opts *globalOptions) completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Decoded directly: loading would merge the profile being completed.
+	var cfg config.PlugstepConfig
+	if _, err := toml.DecodeFile(filepath.Join(opts.serverDirectory, "plugstep.toml"), &cfg); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var names []string
+	for _, name := range cfg.ProfileNames() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePluginSpec completes the source prefix of a source:name spec.
+func completePluginSpec(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || strings.Contains(toComplete, ":") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return specSources, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/server"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+func newFetchCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "fetch",
+		Short: "Download everything install needs without installing it",
+		Long: "Resolve the server jar and every plugin into the cache and download them into\n" +
+			"the download store, so that `install --offline` can run without network access.",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.offline {
+				return usageError(fmt.Errorf("fetch needs network access, drop --offline"))
+			}
+			ps, err := opts.plugstep(append([]string{cmd.Name()}, args...))
+			if err != nil {
+				return err
+			}
+			return FetchCommand(ps)
+		},
+	}
+}
+
+func FetchCommand(ps *plugstep.Plugstep) error {
+	if utils.StoreDirectory == "" {
+		return exitcode.Errorf(exitcode.Config, "no download store to fetch into, set $%s", utils.StoreEnv)
+	}
+
+	var failed []string
+	fetched, stored := 0, 0
+	fetch := func(name, url, checksumType, checksum string) {
+		if utils.InStore(checksumType, checksum) {
+			stored++
+			log.Debug("Already stored", "name", name)
+			return
+		}
+		if err := utils.StoreDownload(url, checksumType, checksum, nil); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", name, err))
+			return
+		}
+		fetched++
+		log.Info("Fetched", "name", name)
+	}
+
+	serverPlan, err := server.PlanServer(ps)
+	if err != nil {
+		failed = append(failed, fmt.Sprintf("server.jar: %s", err))
+	} else {
+		fetch("server.jar", serverPlan.Download.URL, "sha256", serverPlan.Download.Checksum)
+	}
+
+	plan, err := plugins.PlanPlugins(ps)
+	if err != nil {
+		return fmt.Errorf("failed to plan plugins: %w", err)
+	}
+	for _, p := range plan.Plugins {
+		name := *p.Plugin.Resource
+		switch {
+		case p.Err != nil:
+			failed = append(failed, fmt.Sprintf("%s: %s", name, p.Err))
+		case !p.HasChecksum():
+			log.Warn("Can't fetch a plugin without a checksum, offline installs keep the jar already installed", "name", name)
+		default:
+			fetch(name, p.Download.URL, string(p.Download.ChecksumType), p.Download.Checksum)
+		}
+	}
+
+	log.Info("Fetch complete.", "fetched", fetched, "already-stored", stored, "failed", len(failed), "store", utils.StoreDirectory)
+	if len(failed) > 0 {
+		return exitcode.Errorf(exitcode.Partial, "%d item(s) could not be fetched:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
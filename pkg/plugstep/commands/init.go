@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/setup"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+// InitOptions configures InitCommand.
+type InitOptions struct {
+	// Server holds the values given on the command line. An empty project
+	// is taken from the template.
+	Server   setup.SetupResult
+	Template string
+	Force    bool
+	// Interactive runs the setup wizard instead of using Server.
+	Interactive bool
+}
+
+func newInitCommand(opts *globalOptions) *cobra.Command {
+	initOpts := InitOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create plugstep.toml",
+		Long: "Create plugstep.toml in the server directory.\n\n" +
+			"Without flags on a terminal this starts the setup wizard. Otherwise the\n" +
+			"flags are checked against Fill and written without prompting.\n\n" +
+			"Templates:\n" + setup.TemplateHelp(),
+		Example: "  plugstep init\n" +
+			"  plugstep init --vendor papermc --project paper --mc 1.21.8 --build latest\n" +
+			"  plugstep init --template proxy",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			initOpts.Interactive = setup.IsInteractive() &&
+				!flags.Changed("vendor") && !flags.Changed("project") && !flags.Changed("mc") &&
+				!flags.Changed("build") && !flags.Changed("template")
+			return InitCommand(opts.serverDirectory, initOpts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&initOpts.Server.Vendor, "vendor", string(config.ServerJarVendorPaperMC), "server jar vendor")
+	flags.StringVar(&initOpts.Server.Project, "project", "", "server project, e.g. paper, folia or velocity (default: from template)")
+	flags.StringVar(&initOpts.Server.MinecraftVersion, "mc", "", "Minecraft version (default: newest)")
+	flags.StringVar(&initOpts.Server.BuildVersion, "build", "latest", "build number or latest")
+	flags.StringVar(&initOpts.Template, "template", setup.DefaultTemplate, "server template")
+	flags.BoolVar(&initOpts.Force, "force", false, "overwrite an existing plugstep.toml")
+	cmd.RegisterFlagCompletionFunc("template", cobra.FixedCompletions(setup.TemplateNames(), cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("vendor", cobra.FixedCompletions([]string{string(config.ServerJarVendorPaperMC)}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func InitCommand(serverDirectory string, opts InitOptions) error {
+	configPath := filepath.Join(serverDirectory, "plugstep.toml")
+	if _, err := os.Stat(configPath); err == nil && !opts.Force {
+		return exitcode.Errorf(exitcode.Usage, "plugstep.toml already exists in %s (use --force to overwrite)", serverDirectory)
+	}
+
+	var result *setup.SetupResult
+	if opts.Interactive {
+		var err error
+		result, err = setup.NewSetupWizard().Run()
+		if err != nil {
+			return fmt.Errorf("setup wizard failed: %w", err)
+		}
+	} else {
+		template, ok := setup.Templates[opts.Template]
+		if !ok {
+			return exitcode.Errorf(exitcode.Usage, "unknown template %q (available: %v)", opts.Template, setup.TemplateNames())
+		}
+
+		server := opts.Server
+		result = &server
+		if result.Project == "" {
+			result.Project = template.Project
+		}
+
+		log.Info("Checking server settings...", "project", result.Project, "minecraft-version", result.MinecraftVersion, "build", result.BuildVersion)
+		if err := setup.NewPaperMCClient(config.DefaultEndpoint(string(config.ServerJarVendorPaperMC))).Validate(result); err != nil {
+			return err
+		}
+	}
+
+	if err := setup.WriteConfig(configPath, result); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	log.Info("Created plugstep.toml", "project", result.Project, "minecraft-version", result.MinecraftVersion, "build", result.BuildVersion)
+	return nil
+}
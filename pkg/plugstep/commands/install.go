@@ -1,14 +1,260 @@
 package commands
 
 import (
-	"github.com/charmbracelet/log"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/bundle"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/server"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/workspace"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
 )
 
-func InstallCommand(ps *plugstep.Plugstep) {
+func newInstallCommand(opts *globalOptions) *cobra.Command {
+	var (
+		dryRun         bool
+		pruneUnmanaged bool
+		outputMode     string
+		jsonOutput     bool
+		all            bool
+		fromBundle     string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "install",
+		Aliases: []string{"i"},
+		Short:   "Download the server jar and all plugins",
+		Example: "  plugstep install\n" +
+			"  plugstep install --dry-run\n" +
+			"  plugstep install --output=plain\n" +
+			"  plugstep install --all\n" +
+			"  plugstep install --from-bundle server.tar.zst",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jsonOutput {
+				outputMode = string(output.ModeJSON)
+			}
+			mode, err := output.ParseMode(outputMode)
+			if err != nil {
+				return usageError(err)
+			}
+
+			if all && fromBundle != "" {
+				return usageError(fmt.Errorf("--from-bundle installs a single server, it can't be combined with --all"))
+			}
+
+			if all {
+				w, err := opts.workspace()
+				if err != nil {
+					return err
+				}
+				options := plugstep.Options{PruneUnmanaged: pruneUnmanaged, Output: mode}
+				if dryRun {
+					return PlanAllCommand(w, append([]string{cmd.Name()}, args...), options)
+				}
+				return InstallAllCommand(w, append([]string{cmd.Name()}, args...), options)
+			}
+
+			// Everything comes from the bundle, nothing may go out, not even
+			// the includes of plugstep.toml.
+			var b *bundle.Bundle
+			if fromBundle != "" {
+				b, err = bundle.Open(fromBundle)
+				if err != nil {
+					return err
+				}
+				defer b.Close()
+				utils.Offline = true
+				if err := cacheBundledIncludes(opts.serverDirectory, b); err != nil {
+					return err
+				}
+			}
+
+			ps, err := opts.plugstep(append([]string{cmd.Name()}, args...))
+			if err != nil {
+				return err
+			}
+			ps.Options.PruneUnmanaged = pruneUnmanaged
+			ps.Options.Output = mode
+
+			if b != nil {
+				ps.Options.Bundle = b
+				log.Info("Installing from bundle", "file", fromBundle, "created", b.Metadata.Created.Format(time.DateTime))
+			}
+
+			if dryRun {
+				return PlanCommand(ps)
+			}
+			return InstallCommand(ps)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&dryRun, "dry-run", false, "show what would be downloaded and removed without touching disk")
+	flags.BoolVar(&pruneUnmanaged, "prune-unmanaged", false, "also remove files in plugins/ that Plugstep didn't install")
+	flags.StringVar(&outputMode, "output", "", "progress output: tui, plain or json (default: tui on a terminal, plain otherwise)")
+	flags.BoolVar(&jsonOutput, "json", false, "shorthand for --output=json")
+	flags.BoolVar(&all, "all", false, "install every server in "+workspace.FileName+" in parallel")
+	flags.StringVar(&fromBundle, "from-bundle", "", "install from a bundle written by plugstep vendor, without network access")
+	cmd.MarkFlagFilename("from-bundle", "zst")
+	cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(
+		[]string{string(output.ModeTUI), string(output.ModePlain), string(output.ModeJSON)},
+		cobra.ShellCompDirectiveNoFileComp,
+	))
+
+	return cmd
+}
+
+// cacheBundledIncludes caches the remote includes of the bundle, so that
+// plugstep.toml loads offline.
+func cacheBundledIncludes(serverDirectory string, b *bundle.Bundle) error {
+	if len(b.Metadata.Includes) == 0 {
+		return nil
+	}
+	if err := utils.InitCacheDB(serverDirectory); err != nil {
+		return fmt.Errorf("failed to open the cache for the bundled includes: %w", err)
+	}
+	for _, f := range b.Metadata.Includes {
+		data, err := os.ReadFile(b.Path(f.ChecksumType, f.Checksum))
+		if err != nil {
+			return err
+		}
+		if err := config.CacheInclude(f.ChecksumType, f.Checksum, data); err != nil {
+			return fmt.Errorf("failed to cache include %s: %w", f.URL, err)
+		}
+	}
+	return nil
+}
+
+func newPlanCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "plan",
+		Short: "Show what install would download and remove (same as install --dry-run)",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ps, err := opts.plugstep(append([]string{cmd.Name()}, args...))
+			if err != nil {
+				return err
+			}
+			return PlanCommand(ps)
+		},
+	}
+}
+
+func InstallCommand(ps *plugstep.Plugstep) error {
 	log.Debug("Installing server JAR and all plugins...", "serverjar", ps.Config.Server.Project, "minecraft-version", ps.Config.Server.MinecraftVersion, "plugins", len(ps.Config.Plugins))
-	server.InstallServer(ps)
-	plugins.InstallPlugins(ps)
+	ps.BeginBackup()
+	defer ps.FinishBackup()
+
+	serverErr := server.InstallServer(ps)
+	if serverErr != nil {
+		log.Error("Server jar installation failed", "err", serverErr)
+	}
+	pluginsErr := plugins.InstallPlugins(ps)
+
+	var err error
+	switch {
+	case serverErr != nil && pluginsErr != nil:
+		err = errors.Join(serverErr, pluginsErr)
+	case serverErr != nil && len(ps.Config.Plugins) > 0:
+		err = exitcode.Wrap(exitcode.Partial, fmt.Errorf("server jar failed to install: %w", serverErr))
+	case serverErr != nil:
+		err = serverErr
+	case pluginsErr != nil:
+		err = exitcode.Wrap(exitcode.Partial, pluginsErr)
+	}
+	// A checksum mismatch may be a tampered download, it outranks a partial
+	// install.
+	if exitcode.Of(serverErr) == exitcode.Checksum || exitcode.Of(pluginsErr) == exitcode.Checksum {
+		return exitcode.Wrap(exitcode.Checksum, err)
+	}
+	return err
+}
+
+func PlanCommand(ps *plugstep.Plugstep) error {
+	log.Info("Planning install (dry run, nothing will be written)...")
+
+	downloads := 0
+	upToDate := 0
+	failed := 0
+	var firstErr error
+
+	fmt.Println(headerStyle.Render("SERVER"))
+	serverPlan, err := server.PlanServer(ps)
+	if err != nil {
+		failed++
+		firstErr = err
+		printPlanLine(getSourceBadge(string(ps.Config.Server.Vendor)), "server.jar", "", planFailedStyle.Render("FAILED"), err.Error())
+	} else if serverPlan.UpToDate {
+		upToDate++
+		printPlanLine(getSourceBadge(string(ps.Config.Server.Vendor)), "server.jar", ps.Config.Server.Version, planUpToDateStyle.Render("UP TO DATE"), "")
+	} else {
+		downloads++
+		printPlanLine(getSourceBadge(string(ps.Config.Server.Vendor)), "server.jar", ps.Config.Server.Version, planDownloadStyle.Render("DOWNLOAD"), serverPlan.Download.URL)
+	}
+
+	plan, err := plugins.PlanPlugins(ps)
+	if err != nil {
+		return fmt.Errorf("failed to plan plugins: %w", err)
+	}
+
+	fmt.Println(headerStyle.Render(fmt.Sprintf("PLUGINS (%d)", len(plan.Plugins))))
+	for _, p := range plan.Plugins {
+		badge := getSourceBadge(string(p.Plugin.Source))
+		name := *p.Plugin.Resource
+		switch {
+		case p.Err != nil:
+			failed++
+			if firstErr == nil {
+				firstErr = p.Err
+			}
+			printPlanLine(badge, name, "", planFailedStyle.Render("FAILED"), p.Err.Error())
+		case p.UpToDate:
+			upToDate++
+			printPlanLine(badge, name, p.Download.Version, planUpToDateStyle.Render("UP TO DATE"), "")
+		default:
+			downloads++
+			printPlanLine(badge, name, p.Download.Version, planDownloadStyle.Render("DOWNLOAD"), p.Download.URL)
+		}
+	}
+
+	if len(plan.Removals) > 0 {
+		fmt.Println(headerStyle.Render(fmt.Sprintf("REMOVALS (%d)", len(plan.Removals))))
+		for _, file := range plan.Removals {
+			rel, err := filepath.Rel(ps.ServerDirectory, file)
+			if err != nil {
+				rel = file
+			}
+			fmt.Printf("  %s %s\n", arrowStyle.Render("→"), planFailedStyle.Render(rel))
+		}
+	}
+
+	fmt.Println()
+	log.Info("Plan complete.", "download", downloads, "up-to-date", upToDate, "remove", len(plan.Removals), "failed", failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d item(s) could not be resolved: %w", failed, firstErr)
+	}
+	return nil
+}
+
+func printPlanLine(badge, name, version, action, detail string) {
+	fmt.Printf("  %s %s %s %s %s %s\n",
+		arrowStyle.Render("→"),
+		badge,
+		nameStyle.Width(25).Render(name),
+		versionStyle.Width(20).Render(version),
+		action,
+		descStyle.Render(utils.Redact(detail)),
+	)
 }
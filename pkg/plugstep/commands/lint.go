@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	severityStyles = map[config.Severity]lipgloss.Style{
+		config.SeverityError: lipgloss.NewStyle().Foreground(lipgloss.Color("#f38ba8")).Bold(true),
+		config.SeverityWarn:  lipgloss.NewStyle().Foreground(lipgloss.Color("#f9e2af")),
+		config.SeverityInfo:  lipgloss.NewStyle().Foreground(lipgloss.Color("#7f849c")),
+	}
+)
+
+func newLintCommand(opts *globalOptions) *cobra.Command {
+	var jsonOutput, listRules bool
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check plugstep.toml for risky settings",
+		Long: "Run the lint rules against plugstep.toml. Rules can be disabled with\n" +
+			"  [lint]\n" +
+			"  disable = [\"server-latest\"]\n" +
+			"Exits with code 3 when a rule with severity error reports an issue.",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if listRules {
+				return lintRules(jsonOutput)
+			}
+			return LintCommand(opts.serverDirectory, jsonOutput)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "print the issues as JSON")
+	cmd.Flags().BoolVar(&listRules, "rules", false, "list the available rules instead of linting")
+
+	return cmd
+}
+
+// LintCommand lints the config in serverDirectory and prints the issues found.
+func LintCommand(serverDirectory string, jsonOutput bool) error {
+	cfg, _, err := loadConfig(serverDirectory)
+	if err != nil {
+		return err
+	}
+
+	initPluginCache(serverDirectory)
+
+	issues := cfg.LintIssues()
+
+	counts := map[config.Severity]int{}
+	for _, issue := range issues {
+		counts[issue.Severity]++
+	}
+
+	if jsonOutput {
+		if err := printJSON(issues); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Printf("%s %s %s\n",
+				severityStyles[issue.Severity].Render(fmt.Sprintf("%-5s", issue.Severity)),
+				descStyle.Render(issue.Rule),
+				issue.Message,
+			)
+		}
+		if len(issues) == 0 {
+			log.Info("No lint issues found")
+		} else {
+			fmt.Println(descStyle.Render(fmt.Sprintf("%d error(s), %d warning(s)", counts[config.SeverityError], counts[config.SeverityWarn])))
+		}
+	}
+
+	if counts[config.SeverityError] > 0 {
+		return exitcode.Errorf(exitcode.Config, "lint found %d error(s)", counts[config.SeverityError])
+	}
+	return nil
+}
+
+func lintRules(jsonOutput bool) error {
+	rules := config.LintRules()
+
+	if jsonOutput {
+		type ruleInfo struct {
+			ID          string          `json:"id"`
+			Severity    config.Severity `json:"severity"`
+			Description string          `json:"description"`
+		}
+		infos := make([]ruleInfo, len(rules))
+		for i, r := range rules {
+			infos[i] = ruleInfo{ID: r.ID, Severity: r.Severity, Description: r.Description}
+		}
+		return printJSON(infos)
+	}
+
+	for _, r := range rules {
+		fmt.Printf("%s %s %s\n",
+			nameStyle.Render(fmt.Sprintf("%-20s", r.ID)),
+			severityStyles[r.Severity].Render(fmt.Sprintf("%-5s", r.Severity)),
+			r.Description,
+		)
+	}
+	return nil
+}
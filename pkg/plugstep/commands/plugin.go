@@ -2,20 +2,26 @@ package commands
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/BurntSushi/toml"
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/manifest"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/workspace"
+	"github.com/spf13/cobra"
 )
 
 func initPluginCache(serverDirectory string) {
@@ -63,6 +69,22 @@ var (
 	versionStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#a6e3a1"))
 
+	planDownloadStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#89b4fa")).
+				Bold(true)
+
+	planUpToDateStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#a6e3a1")).
+				Bold(true)
+
+	planFailedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#f38ba8")).
+			Bold(true)
+
+	profileStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#f5c2e7")).
+			Italic(true)
+
 	headerStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#cba6f7")).
 			Bold(true).
@@ -70,47 +92,92 @@ var (
 			MarginBottom(1)
 )
 
-func PluginCommand(args []string, serverDirectory string) {
-	if len(args) < 1 {
-		showPluginHelp()
-		return
-	}
-
-	switch args[0] {
-	case "install", "i":
-		pluginInstall(args[1:], serverDirectory)
-	case "remove", "rm":
-		pluginRemove(args[1:], serverDirectory)
-	case "list", "ls":
-		pluginList(serverDirectory)
-	case "search", "s":
-		pluginSearch(args[1:])
-	case "pin":
-		pluginPin(args[1:], serverDirectory)
-	default:
-		log.Error("Unknown plugin subcommand", "subcommand", args[0])
-		showPluginHelp()
-	}
-}
-
-func showPluginHelp() {
-	fmt.Println("Usage: plugstep plugin <command> [args]")
-	fmt.Println()
-	fmt.Println("Commands:")
-	fmt.Println("  install, i  [source:name[@version]]  Install a plugin (interactive if no args)")
-	fmt.Println("  remove, rm  <name>                   Remove a plugin")
-	fmt.Println("  list, ls                             List installed plugins")
-	fmt.Println("  search, s   <query>                  Search for plugins")
-	fmt.Println("  pin         [name]                   Pin plugin(s) to current version")
-	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("  plugstep plugin install                              (interactive)")
-	fmt.Println("  plugstep plugin install modrinth:luckperms")
-	fmt.Println("  plugstep plugin install hangar:FastAsyncWorldEdit@2.8.1")
-	fmt.Println("  plugstep plugin remove luckperms")
-	fmt.Println("  plugstep plugin search worldedit")
-	fmt.Println("  plugstep plugin pin                                  (pin all)")
-	fmt.Println("  plugstep plugin pin luckperms                        (pin specific)")
+func newPluginCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "plugin",
+		Aliases: []string{"p"},
+		Short:   "Manage the plugins in plugstep.toml",
+		Args:    noSubcommand,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	install := &cobra.Command{
+		Use:     "install [source:name[@version]]",
+		Aliases: []string{"i", "add"},
+		Short:   "Install a plugin (interactive if no args)",
+		Example: "  plugstep plugin install                              (interactive)\n" +
+			"  plugstep plugin install modrinth:luckperms\n" +
+			"  plugstep plugin install hangar:FastAsyncWorldEdit@2.8.1",
+		Args:              usageArgs(cobra.MaximumNArgs(1)),
+		ValidArgsFunction: completePluginSpec,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pluginInstall(args, opts.serverDirectory)
+		},
+	}
+
+	var pruneUnmanaged bool
+	remove := &cobra.Command{
+		Use:               "remove <name>",
+		Aliases:           []string{"rm"},
+		Short:             "Remove a plugin",
+		Example:           "  plugstep plugin remove luckperms",
+		Args:              usageArgs(cobra.ExactArgs(1)),
+		ValidArgsFunction: opts.completeConfiguredPlugins,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pluginRemove(args[0], pruneUnmanaged, opts.serverDirectory)
+		},
+	}
+	remove.Flags().BoolVar(&pruneUnmanaged, "prune-unmanaged", false, "also delete jars Plugstep didn't install whose name contains the plugin name")
+
+	var listJSON, listAll bool
+	list := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List configured plugins",
+		Args:    usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if listAll {
+				w, err := opts.workspace()
+				if err != nil {
+					return err
+				}
+				return PluginListAllCommand(w, listJSON)
+			}
+			return pluginList(opts.serverDirectory, listJSON)
+		},
+	}
+	list.Flags().BoolVar(&listJSON, "json", false, "resolve every plugin and print JSON")
+	list.Flags().BoolVar(&listAll, "all", false, "list the plugins of every server in "+workspace.FileName)
+
+	var searchJSON bool
+	search := &cobra.Command{
+		Use:     "search <query>",
+		Aliases: []string{"s"},
+		Short:   "Search Modrinth and Hangar for plugins",
+		Example: "  plugstep plugin search worldedit",
+		Args:    usageArgs(cobra.MinimumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pluginSearch(opts.serverDirectory, strings.Join(args, " "), searchJSON)
+		},
+	}
+	search.Flags().BoolVar(&searchJSON, "json", false, "print results as JSON")
+
+	pin := &cobra.Command{
+		Use:   "pin [name]",
+		Short: "Pin plugin(s) to their current version",
+		Example: "  plugstep plugin pin                                  (pin all)\n" +
+			"  plugstep plugin pin luckperms                        (pin specific)",
+		Args:              usageArgs(cobra.MaximumNArgs(1)),
+		ValidArgsFunction: opts.completeConfiguredPlugins,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pluginPin(args, opts.serverDirectory)
+		},
+	}
+
+	cmd.AddCommand(install, remove, list, search, pin)
+	return cmd
 }
 
 type PluginSpec struct {
@@ -162,26 +229,59 @@ func loadConfig(serverDirectory string) (*config.PlugstepConfig, string, error)
 	configPath := filepath.Join(serverDirectory, "plugstep.toml")
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, configPath, fmt.Errorf("plugstep.toml not found - run 'plugstep' first to create config")
+		return nil, configPath, exitcode.Errorf(exitcode.Config, "plugstep.toml not found - run 'plugstep init' first to create config")
 	}
 
-	cfg, err := config.LoadPlugstepConfig(configPath)
+	// Remote includes are cached, so the cache is opened before the config.
+	if err := utils.InitCacheDB(serverDirectory); err != nil {
+		log.Debug("Failed to initialize cache", "err", err)
+	}
+
+	cfg, err := config.Load(configPath)
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		return nil, configPath, exitcode.Wrap(exitcode.Config, err)
+	}
 	if err != nil {
+		return nil, configPath, exitcode.Wrap(exitcode.Config, fmt.Errorf("failed to load config: %w", err))
+	}
+	if err := configure(cfg); err != nil {
 		return nil, configPath, err
 	}
 
 	return cfg, configPath, nil
 }
 
-func saveConfig(configPath string, cfg *config.PlugstepConfig) error {
-	f, err := os.Create(configPath)
+// editConfig applies edit to plugstep.toml in place, leaving comments and
+// everything edit doesn't touch as they were.
+func editConfig(configPath string, edit func(d *config.Document) error) error {
+	d, err := config.LoadDocument(configPath)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	if err := edit(d); err != nil {
+		return err
+	}
+	return d.Save(configPath)
+}
 
-	encoder := toml.NewEncoder(f)
-	return encoder.Encode(cfg)
+// pluginKeyValues lists the keys of a [[plugins]] table in the order they are
+// written, skipping unset ones.
+func pluginKeyValues(p config.PluginConfig) []config.KeyValue {
+	values := []config.KeyValue{{Key: "source", Value: string(p.Source)}}
+	if p.Resource != nil {
+		values = append(values, config.KeyValue{Key: "resource", Value: *p.Resource})
+	}
+	if p.Version != nil {
+		values = append(values, config.KeyValue{Key: "version", Value: *p.Version})
+	}
+	if p.DownloadURL != nil {
+		values = append(values, config.KeyValue{Key: "download_url", Value: *p.DownloadURL})
+	}
+	if p.Checksum != nil {
+		values = append(values, config.KeyValue{Key: "checksum", Value: *p.Checksum})
+	}
+	return values
 }
 
 func sourceToConfigSource(source string) config.PluginSource {
@@ -196,24 +296,22 @@ func sourceToConfigSource(source string) config.PluginSource {
 	return ""
 }
 
-func pluginInstall(args []string, serverDirectory string) {
+func pluginInstall(args []string, serverDirectory string) error {
 	var spec *PluginSpec
 	var err error
 
 	if len(args) < 1 {
 		spec, err = interactivePluginInstall()
 		if err != nil {
-			log.Error("Interactive install failed", "err", err)
-			return
+			return fmt.Errorf("interactive install failed: %w", err)
 		}
 		if spec == nil {
-			return
+			return nil
 		}
 	} else {
 		spec, err = parsePluginSpec(args[0])
 		if err != nil {
-			log.Error("Invalid plugin spec", "err", err)
-			return
+			return usageError(err)
 		}
 	}
 
@@ -221,14 +319,13 @@ func pluginInstall(args []string, serverDirectory string) {
 
 	cfg, configPath, err := loadConfig(serverDirectory)
 	if err != nil {
-		log.Error("Failed to load config", "err", err)
-		return
+		return err
 	}
 
 	for _, p := range cfg.Plugins {
 		if p.Resource != nil && *p.Resource == spec.Name {
 			log.Warn("Plugin already configured", "name", spec.Name)
-			return
+			return nil
 		}
 	}
 
@@ -243,45 +340,59 @@ func pluginInstall(args []string, serverDirectory string) {
 	log.Info("Validating plugin...", "source", spec.Source, "name", spec.Name)
 	source := plugins.GetSource(newPlugin.Source)
 	if source == nil {
-		log.Error("Invalid plugin source", "source", spec.Source)
-		return
+		return exitcode.Errorf(exitcode.Usage, "invalid plugin source: %s", spec.Source)
 	}
 
 	_, err = source.GetPluginDownload(newPlugin)
 	if err != nil {
-		log.Error("Plugin or version not found", "name", spec.Name, "err", err)
-		return
+		return fmt.Errorf("plugin or version not found: %s: %w", spec.Name, err)
 	}
 
 	cfg.Plugins = append(cfg.Plugins, newPlugin)
 
-	if err := saveConfig(configPath, cfg); err != nil {
-		log.Error("Failed to save config", "err", err)
-		return
-	}
-
-	log.Info("Added plugin to config", "source", spec.Source, "name", spec.Name)
-
 	ps := &plugstep.Plugstep{
 		ServerDirectory: serverDirectory,
 		Config:          cfg,
 	}
-	plugins.InstallPlugins(ps)
+	ps.BeginBackup()
+	defer ps.FinishBackup()
+
+	err = editConfig(configPath, func(d *config.Document) error {
+		table := "plugins"
+		if cfg.Profile != "" {
+			table = config.ProfilePluginsTable(cfg.Profile)
+		}
+		return d.AppendTable(table, true, pluginKeyValues(newPlugin))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	log.Info("Added plugin to config", "source", spec.Source, "name", spec.Name, "profile", cfg.Profile)
+
+	return plugins.InstallPlugins(ps)
 }
 
-func pluginList(serverDirectory string) {
+func pluginList(serverDirectory string, jsonOutput bool) error {
 	cfg, _, err := loadConfig(serverDirectory)
 	if err != nil {
-		log.Error("Failed to load config", "err", err)
-		return
+		return err
+	}
+
+	if jsonOutput {
+		return pluginListJSON(cfg, serverDirectory)
 	}
 
 	if len(cfg.Plugins) == 0 {
 		fmt.Println(descStyle.Render("No plugins configured"))
-		return
+		return nil
 	}
 
-	fmt.Println(headerStyle.Render(fmt.Sprintf("PLUGINS (%d)", len(cfg.Plugins))))
+	header := fmt.Sprintf("PLUGINS (%d)", len(cfg.Plugins))
+	if cfg.Profile != "" {
+		header += " · PROFILE " + cfg.Profile
+	}
+	fmt.Println(headerStyle.Render(header))
 	for _, p := range cfg.Plugins {
 		name := ""
 		if p.Resource != nil {
@@ -293,13 +404,57 @@ func pluginList(serverDirectory string) {
 		}
 
 		badge := getSourceBadge(string(p.Source))
-		fmt.Printf("  %s %s %s %s\n",
+		origin := ""
+		switch {
+		case p.Profile != "":
+			origin = profileStyle.Render("(" + p.Profile + ")")
+		case p.IncludedFrom != "":
+			origin = profileStyle.Render("(" + p.IncludedFrom + ")")
+		}
+		fmt.Printf("  %s %s %s %s %s\n",
 			arrowStyle.Render("→"),
 			badge,
 			nameStyle.Render(name),
 			versionStyle.Render(version),
+			origin,
 		)
 	}
+	return nil
+}
+
+func pluginListJSON(cfg *config.PlugstepConfig, serverDirectory string) error {
+	reports, err := pluginReports(cfg, serverDirectory)
+	if err != nil {
+		return err
+	}
+	return printJSON(reports)
+}
+
+// pluginReports resolves every plugin of a config and compares it against the
+// jar on disk.
+func pluginReports(cfg *config.PlugstepConfig, serverDirectory string) ([]output.PluginReport, error) {
+	initPluginCache(serverDirectory)
+
+	ps := &plugstep.Plugstep{
+		ServerDirectory: serverDirectory,
+		Config:          cfg,
+	}
+	plan, err := plugins.PlanPlugins(ps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve plugins: %w", err)
+	}
+
+	reports := make([]output.PluginReport, len(plan.Plugins))
+	for i, p := range plan.Plugins {
+		reports[i] = p.Report(serverDirectory)
+	}
+	return reports, nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
 }
 
 func getSourceBadge(source string) string {
@@ -355,11 +510,15 @@ func interactivePluginInstall() (*PluginSpec, error) {
 	log.Info("Searching for plugins...")
 
 	var results []searchResult
+	var err error
 	switch source {
 	case "modrinth":
-		results = searchModrinth(query)
+		results, err = searchModrinth(query)
 	case "hangar":
-		results = searchHangar(query)
+		results, err = searchHangar(query)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	if len(results) == 0 {
@@ -431,68 +590,86 @@ func interactivePluginInstall() (*PluginSpec, error) {
 	return spec, nil
 }
 
-func pluginRemove(args []string, serverDirectory string) {
-	if len(args) < 1 {
-		log.Error("Usage: plugstep plugin remove <name>")
-		return
-	}
-
-	name := args[0]
-
+func pluginRemove(name string, pruneUnmanaged bool, serverDirectory string) error {
 	cfg, configPath, err := loadConfig(serverDirectory)
 	if err != nil {
-		log.Error("Failed to load config", "err", err)
-		return
+		return err
 	}
 
-	found := false
-	newPlugins := make([]config.PluginConfig, 0, len(cfg.Plugins))
-	for _, p := range cfg.Plugins {
+	index := -1
+	for i, p := range cfg.Plugins {
 		if p.Resource != nil && *p.Resource == name {
-			found = true
-			continue
+			index = i
+			break
 		}
-		newPlugins = append(newPlugins, p)
 	}
 
-	if !found {
-		log.Warn("Plugin not found in config", "name", name)
-		return
+	if index < 0 {
+		return exitcode.Errorf(exitcode.Usage, "plugin not found in config: %s", name)
+	}
+	if from := cfg.Plugins[index].IncludedFrom; from != "" {
+		return exitcode.Errorf(exitcode.Usage, "%s comes from the included %s, remove it there", name, from)
 	}
 
-	cfg.Plugins = newPlugins
+	err = editConfig(configPath, func(d *config.Document) error {
+		table, i := cfg.PluginTable(index)
+		return d.RemoveTable(table, i)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	initPluginCache(serverDirectory)
 
-	if err := saveConfig(configPath, cfg); err != nil {
-		log.Error("Failed to save config", "err", err)
-		return
+	m, err := manifest.Load(serverDirectory)
+	if err != nil {
+		return fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	for _, rel := range m.FilesFor(name) {
+		if !m.Owns(rel) {
+			log.Warn("Keeping plugin file modified since install", "file", rel)
+			continue
+		}
+		if err := os.Remove(filepath.Join(serverDirectory, filepath.FromSlash(rel))); err == nil {
+			m.Forget(rel)
+			log.Info("Deleted plugin file", "file", path.Base(rel))
+		}
 	}
 
-	pluginsDir := filepath.Join(serverDirectory, "plugins")
-	files, err := os.ReadDir(pluginsDir)
-	if err == nil {
-		for _, f := range files {
-			if strings.Contains(strings.ToLower(f.Name()), strings.ToLower(name)) && strings.HasSuffix(f.Name(), ".jar") {
-				jarPath := filepath.Join(pluginsDir, f.Name())
-				if err := os.Remove(jarPath); err == nil {
-					log.Info("Deleted plugin file", "file", f.Name())
+	if pruneUnmanaged {
+		pluginsDir := filepath.Join(serverDirectory, "plugins")
+		files, err := os.ReadDir(pluginsDir)
+		if err == nil {
+			for _, f := range files {
+				if strings.Contains(strings.ToLower(f.Name()), strings.ToLower(name)) && strings.HasSuffix(f.Name(), ".jar") {
+					jarPath := filepath.Join(pluginsDir, f.Name())
+					if err := os.Remove(jarPath); err == nil {
+						m.Forget(path.Join("plugins", f.Name()))
+						log.Info("Deleted plugin file", "file", f.Name())
+					}
 				}
 			}
 		}
 	}
 
 	log.Info("Removed plugin from config", "name", name)
+
+	if err := m.Save(); err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+	return nil
 }
 
-func pluginPin(args []string, serverDirectory string) {
+func pluginPin(args []string, serverDirectory string) error {
 	cfg, configPath, err := loadConfig(serverDirectory)
 	if err != nil {
-		log.Error("Failed to load config", "err", err)
-		return
+		return err
 	}
 
 	if len(cfg.Plugins) == 0 {
 		log.Warn("No plugins configured")
-		return
+		return nil
 	}
 
 	initPluginCache(serverDirectory)
@@ -502,8 +679,9 @@ func pluginPin(args []string, serverDirectory string) {
 		targetName = args[0]
 	}
 
-	pinned := 0
+	pins := map[int]string{}
 	skipped := 0
+	var failures []error
 
 	for i := range cfg.Plugins {
 		p := &cfg.Plugins[i]
@@ -526,6 +704,15 @@ func pluginPin(args []string, serverDirectory string) {
 			continue
 		}
 
+		// Skip plugins of included files, they're pinned there
+		if p.IncludedFrom != "" {
+			if targetName != "" {
+				log.Warn("Cannot pin included plugin, pin it in the included file", "name", name, "include", p.IncludedFrom)
+			}
+			skipped++
+			continue
+		}
+
 		// Skip custom plugins (no version to pin)
 		if p.Source == config.PluginSourceCustom {
 			if targetName != "" {
@@ -539,12 +726,14 @@ func pluginPin(args []string, serverDirectory string) {
 		source := plugins.GetSource(p.Source)
 		if source == nil {
 			log.Error("Invalid plugin source", "name", name)
+			failures = append(failures, exitcode.Errorf(exitcode.Config, "invalid plugin source for %s", name))
 			continue
 		}
 
 		download, err := source.GetPluginDownload(*p)
 		if err != nil {
 			log.Error("Failed to get plugin version", "name", name, "err", err)
+			failures = append(failures, fmt.Errorf("%s: %w", name, err))
 			continue
 		}
 
@@ -553,46 +742,77 @@ func pluginPin(args []string, serverDirectory string) {
 			continue
 		}
 
-		p.Version = &download.Version
+		pins[i] = download.Version
 		log.Info("Pinned plugin", "name", name, "version", download.Version)
-		pinned++
 	}
 
-	if targetName != "" && pinned == 0 && skipped == 0 {
-		log.Error("Plugin not found", "name", targetName)
-		return
+	pinned := len(pins)
+	if targetName != "" && pinned == 0 && skipped == 0 && len(failures) == 0 {
+		return exitcode.Errorf(exitcode.Usage, "plugin not found: %s", targetName)
 	}
 
 	if pinned > 0 {
-		if err := saveConfig(configPath, cfg); err != nil {
-			log.Error("Failed to save config", "err", err)
-			return
+		err := editConfig(configPath, func(d *config.Document) error {
+			for i, version := range pins {
+				table, index := cfg.PluginTable(i)
+				if err := d.Set(table, index, "version", version); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
 		}
 	}
 
-	log.Info("Pin complete", "pinned", pinned, "skipped", skipped)
+	log.Info("Pin complete", "pinned", pinned, "skipped", skipped, "failed", len(failures))
+
+	switch {
+	case len(failures) == 0:
+		return nil
+	case pinned > 0:
+		return exitcode.Errorf(exitcode.Partial, "%d plugin(s) could not be pinned", len(failures))
+	default:
+		return fmt.Errorf("%d plugin(s) could not be pinned: %w", len(failures), failures[0])
+	}
 }
 
 type searchResult struct {
-	Source      string
-	Name        string
-	Description string
+	Source      string `json:"source"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
 }
 
-func pluginSearch(args []string) {
-	if len(args) < 1 {
-		log.Error("Usage: plugstep plugin search <query>")
-		return
+func pluginSearch(serverDirectory string, query string, jsonOutput bool) error {
+	// Search works without a config, but uses its [sources] when there is one.
+	if _, err := os.Stat(filepath.Join(serverDirectory, "plugstep.toml")); err == nil {
+		if _, _, err := loadConfig(serverDirectory); err != nil {
+			log.Debug("Searching without [sources]", "err", err)
+		}
 	}
 
-	query := strings.Join(args, " ")
+	modrinthResults, modrinthErr := searchModrinth(query)
+	if modrinthErr != nil {
+		log.Warn("Modrinth search failed", "err", modrinthErr)
+	}
+	hangarResults, hangarErr := searchHangar(query)
+	if hangarErr != nil {
+		log.Warn("Hangar search failed", "err", hangarErr)
+	}
+	if modrinthErr != nil && hangarErr != nil {
+		return fmt.Errorf("search failed: %w", errors.Join(modrinthErr, hangarErr))
+	}
 
-	modrinthResults := searchModrinth(query)
-	hangarResults := searchHangar(query)
+	if jsonOutput {
+		results := append([]searchResult{}, modrinthResults...)
+		results = append(results, hangarResults...)
+		return printJSON(results)
+	}
 
 	if len(modrinthResults) == 0 && len(hangarResults) == 0 {
 		fmt.Println(descStyle.Render("No plugins found"))
-		return
+		return nil
 	}
 
 	if len(modrinthResults) > 0 {
@@ -624,19 +844,19 @@ func pluginSearch(args []string) {
 			)
 		}
 	}
+	return nil
 }
 
-func searchModrinth(query string) []searchResult {
-	searchURL := fmt.Sprintf(
-		"https://api.modrinth.com/v2/search?query=%s&facets=%s&limit=10",
+func searchModrinth(query string) ([]searchResult, error) {
+	searchPath := fmt.Sprintf(
+		"/search?query=%s&facets=%s&limit=10",
 		url.QueryEscape(query),
 		url.QueryEscape(`[["project_type:plugin"]]`),
 	)
 
-	r, err := utils.HTTPClient.Get(searchURL)
+	r, err := plugins.Endpoint(config.PluginSourceModrinth).Get(searchPath)
 	if err != nil {
-		log.Debug("Modrinth search failed", "err", err)
-		return nil
+		return nil, err
 	}
 	defer r.Body.Close()
 
@@ -649,8 +869,7 @@ func searchModrinth(query string) []searchResult {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
-		log.Debug("Modrinth search parse failed", "err", err)
-		return nil
+		return nil, fmt.Errorf("failed to parse Modrinth search results: %w", err)
 	}
 
 	var results []searchResult
@@ -662,19 +881,15 @@ func searchModrinth(query string) []searchResult {
 		})
 	}
 
-	return results
+	return results, nil
 }
 
-func searchHangar(query string) []searchResult {
-	searchURL := fmt.Sprintf(
-		"https://hangar.papermc.io/api/v1/projects?q=%s&limit=10",
-		url.QueryEscape(query),
-	)
+func searchHangar(query string) ([]searchResult, error) {
+	searchPath := fmt.Sprintf("/projects?q=%s&limit=10", url.QueryEscape(query))
 
-	r, err := utils.HTTPClient.Get(searchURL)
+	r, err := plugins.Endpoint(config.PluginSourcePaperHangar).Get(searchPath)
 	if err != nil {
-		log.Debug("Hangar search failed", "err", err)
-		return nil
+		return nil, err
 	}
 	defer r.Body.Close()
 
@@ -686,8 +901,7 @@ func searchHangar(query string) []searchResult {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
-		log.Debug("Hangar search parse failed", "err", err)
-		return nil
+		return nil, fmt.Errorf("failed to parse Hangar search results: %w", err)
 	}
 
 	var results []searchResult
@@ -699,5 +913,5 @@ func searchHangar(query string) []searchResult {
 		})
 	}
 
-	return results
+	return results, nil
 }
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/backup"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+func newRollbackCommand(opts *globalOptions) *cobra.Command {
+	var list bool
+
+	cmd := &cobra.Command{
+		Use:   "rollback [id]",
+		Short: "Restore the files replaced by an install",
+		Long: "Restore the files replaced by an install from .plugstep/backups,\n" +
+			"using the newest backup unless an id is given.",
+		Args:              usageArgs(cobra.MaximumNArgs(1)),
+		ValidArgsFunction: opts.completeBackups,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := ""
+			if len(args) > 0 {
+				id = args[0]
+			}
+			return RollbackCommand(id, list, opts.serverDirectory)
+		},
+	}
+	cmd.Flags().BoolVar(&list, "list", false, "list available backups instead of restoring")
+
+	return cmd
+}
+
+func RollbackCommand(id string, list bool, serverDirectory string) error {
+	if list {
+		return listBackups(serverDirectory)
+	}
+
+	s, err := backup.Restore(serverDirectory, id)
+	if err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+
+	log.Info("Rolled back", "backup", s.ID, "restored", len(s.Files), "removed", len(s.Added))
+	return nil
+}
+
+func listBackups(serverDirectory string) error {
+	snapshots, err := backup.List(serverDirectory)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Println(descStyle.Render("No backups found"))
+		return nil
+	}
+
+	fmt.Println(headerStyle.Render(fmt.Sprintf("BACKUPS (%d)", len(snapshots))))
+	for _, s := range snapshots {
+		fmt.Printf("  %s %s %s\n",
+			arrowStyle.Render("→"),
+			nameStyle.Width(25).Render(s.ID),
+			descStyle.Render(fmt.Sprintf("%d replaced, %d added", len(s.Files), len(s.Added))),
+		)
+	}
+	return nil
+}
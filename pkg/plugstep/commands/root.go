@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+const initMessage = "Initializing Plugstep ♪(๑ᴖ◡ᴖ๑)♪"
+
+// globalOptions holds the flags shared by every command.
+type globalOptions struct {
+	serverDirectory string
+	debug           bool
+	flushCache      bool
+	throttleNetwork int
+	profile         string
+	offline         bool
+}
+
+// NewRootCommand builds the plugstep command tree. showVersion renders the
+// version banner for `plugstep` and `plugstep version`.
+func NewRootCommand(showVersion func()) *cobra.Command {
+	opts := &globalOptions{}
+
+	root := &cobra.Command{
+		Use:   "plugstep",
+		Short: "Declarative Minecraft server and plugin manager",
+		Long: "Plugstep installs the server jar and plugins described in plugstep.toml.\n" +
+			"Run without a command to show the version.",
+		Args: noSubcommand,
+		Run: func(cmd *cobra.Command, args []string) {
+			showVersion()
+		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return opts.apply(cmd)
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError(err)
+	})
+
+	flags := root.PersistentFlags()
+	flags.BoolVarP(&opts.debug, "debug", "d", false, "enable debug logging")
+	flags.StringVar(&opts.serverDirectory, "dir", ".", "path to server")
+	flags.BoolVar(&opts.flushCache, "flush-cache", false, "clear the whole metadata cache before running (same as cache clear --all)")
+	flags.IntVar(&opts.throttleNetwork, "throttle-network", 0, "throttle download speed in KB/s (for testing)")
+	flags.BoolVar(&opts.offline, "offline", false, "use only cached metadata and stored downloads (see plugstep fetch)")
+	flags.StringVar(&opts.profile, "profile", "", "merge a [profiles.<name>] overlay onto plugstep.toml (default $"+config.ProfileEnv+")")
+	root.MarkPersistentFlagDirname("dir")
+	root.RegisterFlagCompletionFunc("profile", opts.completeProfiles)
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:     "version",
+			Aliases: []string{"v"},
+			Short:   "Show the version",
+			Args:    usageArgs(cobra.NoArgs),
+			Run: func(cmd *cobra.Command, args []string) {
+				showVersion()
+			},
+		},
+		newCacheCommand(opts),
+		newFetchCommand(opts),
+		newInitCommand(opts),
+		newInstallCommand(opts),
+		newLintCommand(opts),
+		newPlanCommand(opts),
+		newPluginCommand(opts),
+		newRollbackCommand(opts),
+		newSchemaCommand(opts),
+		newUpgradeCommand(opts),
+		newUpgradeMinecraftCommand(opts),
+		newValidateCommand(opts),
+		newVendorCommand(opts),
+	)
+
+	return root
+}
+
+// apply configures logging, networking and the cache from the global flags
+// before a command runs.
+func (opts *globalOptions) apply(cmd *cobra.Command) error {
+	if isCompletionCommand(cmd) {
+		return nil
+	}
+
+	// Keep the colors detected for the terminal, the writer hides it.
+	profile := lipgloss.ColorProfile()
+	log.SetOutput(utils.RedactingWriter(os.Stderr))
+	log.SetColorProfile(profile)
+
+	log.Info(initMessage)
+
+	config.SelectProfile(opts.profile)
+	if profile := config.ActiveProfile(); profile != "" {
+		log.Info("Using profile", "profile", profile)
+	}
+
+	if opts.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	log.Debug("Debug logging enabled.")
+
+	utils.StoreDirectory = utils.DefaultStoreDirectory()
+	log.Debug("Using download store", "dir", utils.StoreDirectory)
+
+	utils.Offline = opts.offline
+	if opts.offline {
+		log.Info("Offline, using cached metadata and stored downloads only")
+	}
+
+	if opts.throttleNetwork > 0 {
+		utils.SetThrottledTransport(opts.throttleNetwork * 1024)
+		log.Debug("Network throttling enabled", "kb/s", opts.throttleNetwork)
+	}
+
+	if opts.flushCache && openCache(opts.serverDirectory) {
+		if err := CacheClearCommand(""); err != nil {
+			log.Error("Failed to flush cache", "err", err)
+		}
+	}
+
+	return nil
+}
+
+// plugstep loads the server directory for commands that need plugstep.toml,
+// running the setup wizard when it doesn't exist yet and Plugstep runs on a
+// terminal.
+func (opts *globalOptions) plugstep(args []string) (*plugstep.Plugstep, error) {
+	ps := plugstep.CreatePlugstep(args, opts.serverDirectory)
+	if err := ps.Init(); err != nil {
+		return nil, err
+	}
+	if err := configure(ps.Config); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// configure applies the settings of a loaded config that outlive it: how
+// plugin sources are reached and the network every request goes through.
+func configure(cfg *config.PlugstepConfig) error {
+	plugins.Configure(cfg)
+	err := utils.ConfigureNetwork(utils.NetworkOptions{
+		Proxy:    cfg.Network.Proxy,
+		CABundle: cfg.Network.CABundle,
+	})
+	if err != nil {
+		return exitcode.Wrap(exitcode.Config, err)
+	}
+	return nil
+}
+
+func isCompletionCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return true
+		}
+	}
+	return false
+}
+
+// noSubcommand rejects positional arguments on commands that only group
+// subcommands, so typos fail instead of silently printing help.
+func noSubcommand(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return exitcode.Errorf(exitcode.Usage, "unknown command %q for %q", args[0], cmd.CommandPath())
+	}
+	return nil
+}
+
+// usageArgs tags errors from a positional argument validator with
+// exitcode.Usage.
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := validate(cmd, args); err != nil {
+			return exitcode.Wrap(exitcode.Usage, fmt.Errorf("%w\nUsage: %s", err, cmd.UseLine()))
+		}
+		return nil
+	}
+}
+
+// usageError tags an argument parsing error with exitcode.Usage.
+func usageError(err error) error {
+	return exitcode.Wrap(exitcode.Usage, fmt.Errorf("invalid arguments: %w", err))
+}
+
+// NormalizeLegacyFlags rewrites the single-dash long flags accepted by older
+// releases (e.g. -dir) to their double-dash form.
+func NormalizeLegacyFlags(args []string) []string {
+	legacy := []string{"debug", "dir", "flush-cache", "throttle-network"}
+
+	normalized := make([]string, len(args))
+	for i, arg := range args {
+		normalized[i] = arg
+		if arg == "--" {
+			copy(normalized[i:], args[i:])
+			break
+		}
+		for _, name := range legacy {
+			if arg == "-"+name || strings.HasPrefix(arg, "-"+name+"=") {
+				normalized[i] = "-" + arg
+				break
+			}
+		}
+	}
+	return normalized
+}
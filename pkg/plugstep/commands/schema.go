@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+func newSchemaCommand(opts *globalOptions) *cobra.Command {
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of plugstep.toml",
+		Long: "Print a JSON Schema of plugstep.toml for editors that validate and complete\n" +
+			"TOML, e.g. VS Code with Even Better TOML. Reference it from a config with\n" +
+			"  #:schema ./plugstep.schema.json",
+		Example: "  plugstep schema -o plugstep.schema.json",
+		Args:    usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return SchemaCommand(outputPath)
+		},
+	}
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "write the schema to a file instead of stdout")
+
+	return cmd
+}
+
+// SchemaCommand prints the schema, or writes it to outputPath when set.
+func SchemaCommand(outputPath string) error {
+	data, err := config.SchemaJSON()
+	if err != nil {
+		return fmt.Errorf("failed to generate schema: %w", err)
+	}
+
+	if outputPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+	log.Info("Wrote schema", "file", outputPath)
+	return nil
+}
@@ -1,18 +1,38 @@
 package commands
 
 import (
+	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
 )
 
 const latestVersionURL = "https://releases.perny.dev/mineframe/plugstep/latest"
 
-func UpgradeCommand(serverDirectory string, targetVersion string) {
+func newUpgradeCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:     "upgrade [version]",
+		Aliases: []string{"u"},
+		Short:   "Pin the Plugstep wrapper to the latest or a given version",
+		Args:    usageArgs(cobra.MaximumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targetVersion := ""
+			if len(args) > 0 {
+				targetVersion = args[0]
+			}
+			return UpgradeCommand(opts.serverDirectory, targetVersion)
+		},
+	}
+}
+
+func UpgradeCommand(serverDirectory string, targetVersion string) error {
 	versionFile := filepath.Join(serverDirectory, ".plugstep-version")
 
 	currentVersion := ""
@@ -26,15 +46,17 @@ func UpgradeCommand(serverDirectory string, targetVersion string) {
 	} else {
 		r, err := utils.HTTPClient.Get(latestVersionURL)
 		if err != nil {
-			log.Error("Failed to fetch latest version", "err", err)
-			return
+			return fmt.Errorf("failed to fetch latest version: %w", err)
 		}
 		defer r.Body.Close()
 
+		if r.StatusCode != http.StatusOK {
+			return exitcode.Errorf(exitcode.Network, "failed to fetch latest version: %s", r.Status)
+		}
+
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			log.Error("Failed to read latest version", "err", err)
-			return
+			return fmt.Errorf("failed to read latest version: %w", err)
 		}
 
 		newVersion = strings.TrimSpace(string(body))
@@ -42,12 +64,11 @@ func UpgradeCommand(serverDirectory string, targetVersion string) {
 
 	if currentVersion == newVersion {
 		log.Info("Already on version", "version", currentVersion)
-		return
+		return nil
 	}
 
 	if err := os.WriteFile(versionFile, []byte(newVersion), 0644); err != nil {
-		log.Error("Failed to write version file", "err", err)
-		return
+		return fmt.Errorf("failed to write version file: %w", err)
 	}
 
 	if currentVersion == "" {
@@ -55,4 +76,5 @@ func UpgradeCommand(serverDirectory string, targetVersion string) {
 	} else {
 		log.Info("Upgraded Plugstep Wrapper pinned version", "from", currentVersion, "to", newVersion)
 	}
+	return nil
 }
@@ -0,0 +1,289 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/setup"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+type compatStatus string
+
+const (
+	compatCompatible  compatStatus = "compatible"
+	compatNeedsUpdate compatStatus = "needs update"
+	compatNoRelease   compatStatus = "no release"
+	compatUnknown     compatStatus = "unknown"
+)
+
+type compatRow struct {
+	kind    string
+	name    string
+	current string
+	target  string
+	status  compatStatus
+	note    string
+}
+
+var (
+	compatStyles = map[compatStatus]lipgloss.Style{
+		compatCompatible:  lipgloss.NewStyle().Foreground(lipgloss.Color("#a6e3a1")),
+		compatNeedsUpdate: lipgloss.NewStyle().Foreground(lipgloss.Color("#f9e2af")),
+		compatNoRelease:   lipgloss.NewStyle().Foreground(lipgloss.Color("#f38ba8")),
+		compatUnknown:     lipgloss.NewStyle().Foreground(lipgloss.Color("#7f849c")),
+	}
+)
+
+func newUpgradeMinecraftCommand(opts *globalOptions) *cobra.Command {
+	var write, force bool
+
+	cmd := &cobra.Command{
+		Use:     "upgrade-mc <minecraft-version>",
+		Short:   "Check the server and plugins against a Minecraft version",
+		Example: "  plugstep upgrade-mc 1.21.8 --write",
+		Args:    usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return UpgradeMinecraftCommand(args[0], write, force, opts.serverDirectory)
+		},
+	}
+	cmd.Flags().BoolVar(&write, "write", false, "rewrite plugstep.toml when every component is satisfiable")
+	cmd.Flags().BoolVar(&force, "force", false, "with --write, rewrite plugstep.toml even when some components couldn't be checked")
+
+	return cmd
+}
+
+func UpgradeMinecraftCommand(targetVersion string, write, force bool, serverDirectory string) error {
+	cfg, configPath, err := loadConfig(serverDirectory)
+	if err != nil {
+		return err
+	}
+
+	initPluginCache(serverDirectory)
+
+	log.Info("Checking compatibility...", "from", cfg.Server.MinecraftVersion, "to", targetVersion)
+
+	rows := []compatRow{checkServerCompat(cfg, targetVersion)}
+	for _, p := range cfg.Plugins {
+		rows = append(rows, checkPluginCompat(p, targetVersion))
+	}
+
+	renderCompatTable(rows)
+
+	satisfiable := true
+	unknown := 0
+	for _, r := range rows {
+		switch r.status {
+		case compatNoRelease:
+			satisfiable = false
+		case compatUnknown:
+			unknown++
+		}
+	}
+
+	if !satisfiable {
+		return fmt.Errorf("some components have no release for Minecraft %s", targetVersion)
+	}
+
+	if !write {
+		log.Info("Run again with --write to update plugstep.toml")
+		return nil
+	}
+
+	if unknown > 0 && !force {
+		return fmt.Errorf("%d component(s) couldn't be checked against Minecraft %s, run again with --force to update plugstep.toml anyway", unknown, targetVersion)
+	}
+
+	minecraftChanged := cfg.Server.MinecraftVersion != targetVersion
+	applyCompatRows(cfg, rows, targetVersion)
+
+	err = editConfig(configPath, func(d *config.Document) error {
+		if minecraftChanged {
+			if err := setUnlessInterpolated(d, cfg.ServerTable("minecraft_version"), 0, "minecraft_version", cfg.Server.MinecraftVersion); err != nil {
+				return err
+			}
+		}
+		if rows[0].status == compatNeedsUpdate {
+			if err := setUnlessInterpolated(d, cfg.ServerTable("version"), 0, "version", cfg.Server.Version); err != nil {
+				return err
+			}
+		}
+		for i, row := range rows[1:] {
+			if row.status == compatNeedsUpdate {
+				if from := cfg.Plugins[i].IncludedFrom; from != "" {
+					log.Warn("Update the included plugin in its file", "name", row.name, "version", row.target, "include", from)
+					continue
+				}
+				table, index := cfg.PluginTable(i)
+				if err := setUnlessInterpolated(d, table, index, "version", *cfg.Plugins[i].Version); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	log.Info("Updated plugstep.toml", "minecraft_version", targetVersion)
+	return nil
+}
+
+// setUnlessInterpolated sets key to value unless plugstep.toml expands it from
+// a variable, which writing the value would replace.
+func setUnlessInterpolated(d *config.Document, table string, index int, key string, value string) error {
+	if raw, ok := d.Raw(table, index, key); ok && strings.Contains(raw, "${") {
+		log.Warn("Not updating a value set from a variable, update the variable instead", "table", table, "key", key, "value", value)
+		return nil
+	}
+	return d.Set(table, index, key, value)
+}
+
+func checkServerCompat(cfg *config.PlugstepConfig, targetVersion string) compatRow {
+	server := cfg.Server
+	row := compatRow{
+		kind:    "server",
+		name:    server.Project,
+		current: server.Version,
+	}
+
+	if server.Vendor != config.ServerJarVendorPaperMC {
+		row.status = compatUnknown
+		row.note = "unsupported vendor"
+		return row
+	}
+
+	client := setup.NewPaperMCClient(cfg.VendorEndpoint(server.Vendor))
+	versions, err := client.GetVersions(server.Project)
+	if err != nil {
+		row.status = compatUnknown
+		row.note = err.Error()
+		return row
+	}
+	if !slices.Contains(versions, targetVersion) {
+		row.status = compatNoRelease
+		return row
+	}
+
+	builds, err := client.GetBuilds(server.Project, targetVersion)
+	if err != nil {
+		row.status = compatUnknown
+		row.note = err.Error()
+		return row
+	}
+	if len(builds) == 0 {
+		row.status = compatNoRelease
+		return row
+	}
+
+	switch {
+	case server.Version == "latest":
+		row.status = compatCompatible
+		row.target = "latest"
+	case server.MinecraftVersion == targetVersion && slices.Contains(builds, server.Version):
+		row.status = compatCompatible
+		row.target = server.Version
+	default:
+		row.status = compatNeedsUpdate
+		row.target = builds[0]
+	}
+	return row
+}
+
+func checkPluginCompat(p config.PluginConfig, targetVersion string) compatRow {
+	row := compatRow{
+		kind:    string(p.Source),
+		current: "(latest)",
+	}
+	if p.Resource != nil {
+		row.name = *p.Resource
+	}
+	isPinned := p.Version != nil && *p.Version != ""
+	if isPinned {
+		row.current = *p.Version
+	}
+
+	source := plugins.GetSource(p.Source)
+	checker, ok := source.(plugins.CompatibilityChecker)
+	if !ok || p.Resource == nil {
+		row.status = compatUnknown
+		row.note = "source does not publish Minecraft versions"
+		return row
+	}
+
+	versions, err := checker.CompatibleVersions(p, targetVersion)
+	if err != nil {
+		row.status = compatUnknown
+		row.note = err.Error()
+		return row
+	}
+	if len(versions) == 0 {
+		row.status = compatNoRelease
+		return row
+	}
+
+	current := row.current
+	if !isPinned {
+		download, err := source.GetPluginDownload(p)
+		if err != nil {
+			row.status = compatUnknown
+			row.note = err.Error()
+			return row
+		}
+		current = download.Version
+	}
+
+	if slices.Contains(versions, current) {
+		row.status = compatCompatible
+		row.target = row.current
+	} else {
+		row.status = compatNeedsUpdate
+		row.target = versions[0]
+	}
+	return row
+}
+
+// applyCompatRows moves the config to targetVersion, bumping every pin that
+// needs an update. Rows must be in the order produced by UpgradeMinecraftCommand.
+func applyCompatRows(cfg *config.PlugstepConfig, rows []compatRow, targetVersion string) {
+	cfg.Server.MinecraftVersion = targetVersion
+	if rows[0].target != "" {
+		cfg.Server.Version = rows[0].target
+	}
+
+	for i, row := range rows[1:] {
+		if row.status != compatNeedsUpdate {
+			continue
+		}
+		version := row.target
+		cfg.Plugins[i].Version = &version
+	}
+}
+
+func renderCompatTable(rows []compatRow) {
+	fmt.Println(headerStyle.Render(fmt.Sprintf("COMPATIBILITY (%d)", len(rows))))
+	for _, r := range rows {
+		target := r.target
+		if target == "" {
+			target = "-"
+		}
+		line := fmt.Sprintf("  %s %s %s %s %s %s",
+			arrowStyle.Render("→"),
+			getSourceBadge(r.kind),
+			nameStyle.Width(25).Render(r.name),
+			descStyle.Width(20).Render(r.current),
+			versionStyle.Width(20).Render(target),
+			compatStyles[r.status].Render(string(r.status)),
+		)
+		if r.note != "" {
+			line += " " + descStyle.Render(r.note)
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+func newValidateCommand(opts *globalOptions) *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "validate [file...]",
+		Short: "Check plugstep.toml for mistakes",
+		Long: "Check config files for syntax errors, unknown keys and missing or invalid\n" +
+			"values, reporting every problem as file:line:column. Defaults to the\n" +
+			"plugstep.toml in the server directory. Exits with code 3 on problems,\n" +
+			"so it can run as a pre-commit hook.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files := args
+			if len(files) == 0 {
+				files = []string{filepath.Join(opts.serverDirectory, "plugstep.toml")}
+			}
+			return ValidateCommand(files, jsonOutput)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"toml"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "print the diagnostics as JSON")
+
+	return cmd
+}
+
+// ValidateCommand validates every file and prints the problems found.
+func ValidateCommand(files []string, jsonOutput bool) error {
+	diagnostics := []config.Diagnostic{}
+	for _, file := range files {
+		found, err := config.Validate(file)
+		if err != nil {
+			return exitcode.Wrap(exitcode.Config, fmt.Errorf("failed to read %s: %w", file, err))
+		}
+		diagnostics = append(diagnostics, found...)
+	}
+
+	if jsonOutput {
+		if err := printJSON(diagnostics); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+	}
+
+	if len(diagnostics) > 0 {
+		return exitcode.Errorf(exitcode.Config, "found %d problem(s)", len(diagnostics))
+	}
+	if !jsonOutput {
+		log.Info("Config is valid", "files", len(files))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/bundle"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/server"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"github.com/charmbracelet/log"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+func newVendorCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "vendor <out.tar.zst>",
+		Short: "Bundle the server jar and every plugin for an install without network access",
+		Long: "Resolve plugstep.toml and write the server jar and every plugin, with the URLs,\n" +
+			"versions and checksums they were resolved to, into a bundle that\n" +
+			"`install --from-bundle` installs on a host without network access.",
+		Example: "  plugstep vendor server.tar.zst\n" +
+			"  plugstep install --from-bundle server.tar.zst",
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ps, err := opts.plugstep(append([]string{cmd.Name()}, args...))
+			if err != nil {
+				return err
+			}
+			return VendorCommand(ps, args[0])
+		},
+	}
+}
+
+func VendorCommand(ps *plugstep.Plugstep, out string) error {
+	dir, err := os.MkdirTemp("", "plugstep-vendor-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	metadata := bundle.Metadata{Created: time.Now().UTC()}
+	failed := 0
+	var firstErr error
+	fail := func(name string, err error) {
+		log.Error("Failed to vendor", "name", name, "err", err)
+		failed++
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	serverPlan, err := server.PlanServer(ps)
+	if err != nil {
+		fail("server.jar", err)
+	} else {
+		f := bundle.File{URL: serverPlan.Download.URL, ChecksumType: "sha256", Checksum: serverPlan.Download.Checksum}
+		if err := vendorFile(dir, &f); err != nil {
+			fail("server.jar", err)
+		}
+		metadata.Server = bundle.NewServer(ps.Config.Server, serverPlan.Download.Build, f)
+	}
+
+	plan, err := plugins.PlanPlugins(ps)
+	if err != nil {
+		return fmt.Errorf("failed to plan plugins: %w", err)
+	}
+	for _, p := range plan.Plugins {
+		name := *p.Plugin.Resource
+		if p.Err != nil {
+			fail(name, p.Err)
+			continue
+		}
+		f := bundle.File{URL: p.Download.URL}
+		if p.HasChecksum() {
+			f.ChecksumType = string(p.Download.ChecksumType)
+			f.Checksum = p.Download.Checksum
+		}
+		if err := vendorFile(dir, &f); err != nil {
+			fail(name, err)
+			continue
+		}
+		log.Info("Vendored", "name", name, "version", p.Download.Version)
+		metadata.Plugins = append(metadata.Plugins, bundle.NewPlugin(*p.Plugin, p.Download.Version, f))
+	}
+
+	for _, include := range ps.Config.RemoteIncludes() {
+		f := bundle.File{URL: include.URL, ChecksumType: include.ChecksumType, Checksum: include.Checksum}
+		dest := filepath.Join(dir, f.ChecksumType, f.Checksum)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, include.Data, 0644); err != nil {
+			return err
+		}
+		metadata.Includes = append(metadata.Includes, f)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d item(s) could not be vendored, no bundle was written: %w", failed, firstErr)
+	}
+
+	if err := bundle.Write(out, metadata, dir); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	size := "unknown"
+	if info, err := os.Stat(out); err == nil {
+		size = humanize.Bytes(uint64(info.Size()))
+	}
+	log.Info("Bundle written.", "file", out, "plugins", len(metadata.Plugins), "size", size)
+	return nil
+}
+
+// vendorFile downloads f into dir, laid out like in the bundle. Files without
+// a published checksum are hashed once downloaded, so that installs from the
+// bundle verify them too.
+func vendorFile(dir string, f *bundle.File) error {
+	if f.Checksum != "" {
+		dest := filepath.Join(dir, f.ChecksumType, f.Checksum)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		return utils.DownloadVerified(f.URL, dest, f.ChecksumType, f.Checksum, nil)
+	}
+
+	part, err := os.CreateTemp(dir, "*.part")
+	if err != nil {
+		return err
+	}
+	part.Close()
+	tmp := part.Name()
+	defer os.Remove(tmp)
+
+	if err := utils.DownloadFile(f.URL, tmp); err != nil {
+		return err
+	}
+	checksum, err := utils.CalculateFileSHA256(tmp)
+	if err != nil {
+		return err
+	}
+	f.ChecksumType, f.Checksum = "sha256", checksum
+	dest := filepath.Join(dir, f.ChecksumType, f.Checksum)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
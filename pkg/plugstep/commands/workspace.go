@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/install/plugins"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/workspace"
+	"github.com/charmbracelet/log"
+)
+
+// workspace finds the workspace containing --dir and points the cache at its
+// root, so members resolve every plugin once.
+func (opts *globalOptions) workspace() (*workspace.Workspace, error) {
+	w, err := workspace.Find(opts.serverDirectory)
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.Config, err)
+	}
+
+	if err := utils.InitCacheDB(w.Root); err != nil {
+		log.Debug("Failed to initialize cache", "err", err)
+	}
+	plugins.InitCache()
+
+	log.Info("Using workspace", "root", w.Root, "members", len(w.Members))
+	return w, nil
+}
+
+// memberPlugstep loads a workspace member. Unlike a single server, a member
+// without plugstep.toml is an error rather than a reason to run the wizard.
+func memberPlugstep(args []string, member, dir string) (*plugstep.Plugstep, error) {
+	if _, err := os.Stat(filepath.Join(dir, "plugstep.toml")); err != nil {
+		return nil, exitcode.Errorf(exitcode.Config, "plugstep.toml not found in member %s", member)
+	}
+
+	ps := plugstep.CreatePlugstep(args, dir)
+	if err := ps.Init(); err != nil {
+		return nil, err
+	}
+	if err := configure(ps.Config); err != nil {
+		return nil, err
+	}
+	ps.Options.Member = member
+	return ps, nil
+}
+
+// processSettings is what configure applies to the whole process rather than
+// to one server: the sources with their credentials, endpoints and cache TTLs,
+// and the network.
+type processSettings struct {
+	Sources map[string]config.SourceConfig
+	Network config.NetworkConfig
+	Cache   config.CacheConfig
+	// CustomHosts receive the custom source's credentials when it has no
+	// base_url.
+	CustomHosts []string
+}
+
+func newProcessSettings(cfg *config.PlugstepConfig) processSettings {
+	settings := processSettings{Sources: cfg.Sources, Network: cfg.Network, Cache: cfg.Cache}
+	if _, ok := cfg.Sources[string(config.PluginSourceCustom)]; !ok {
+		return settings
+	}
+	for _, p := range cfg.Plugins {
+		if p.Source != config.PluginSourceCustom || p.DownloadURL == nil {
+			continue
+		}
+		if u, err := url.Parse(*p.DownloadURL); err == nil && !slices.Contains(settings.CustomHosts, u.Host) {
+			settings.CustomHosts = append(settings.CustomHosts, u.Host)
+		}
+	}
+	slices.Sort(settings.CustomHosts)
+	return settings
+}
+
+// memberRunner returns w, or a copy working on one member at a time when the
+// members' process settings differ, so that no member is resolved or
+// downloaded with the credentials, endpoints or proxy of another.
+func memberRunner(w *workspace.Workspace) *workspace.Workspace {
+	var first *processSettings
+	for _, member := range w.Members {
+		cfg, err := config.LoadPlugstepConfig(filepath.Join(w.Dir(member), "plugstep.toml"))
+		if err != nil {
+			// The member reports the error itself.
+			continue
+		}
+		settings := newProcessSettings(cfg)
+		if first == nil {
+			first = &settings
+			continue
+		}
+		if !reflect.DeepEqual(*first, settings) {
+			log.Info("Members configure [sources], [network] or [cache] differently, working on one at a time")
+			serial := *w
+			serial.Jobs = 1
+			return &serial
+		}
+	}
+	return w
+}
+
+// InstallAllCommand installs every member of the workspace in parallel, or one
+// at a time when their process settings differ, and prints a summary. The TUI can't show several servers at once, so it falls
+// back to plain output.
+func InstallAllCommand(w *workspace.Workspace, args []string, options plugstep.Options) error {
+	if options.Output.Resolve() == output.ModeTUI {
+		options.Output = output.ModePlain
+	}
+
+	results := memberRunner(w).Run(func(member, dir string) error {
+		ps, err := memberPlugstep(args, member, dir)
+		if err != nil {
+			log.Error("Failed to load member", "server", member, "err", err)
+			return err
+		}
+		ps.Options.PruneUnmanaged = options.PruneUnmanaged
+		ps.Options.Output = options.Output
+		return InstallCommand(ps)
+	})
+
+	printWorkspaceSummary(results)
+	return workspaceError(results)
+}
+
+// PlanAllCommand plans every member of the workspace, one after the other so
+// their plans don't interleave.
+func PlanAllCommand(w *workspace.Workspace, args []string, options plugstep.Options) error {
+	results := make([]workspace.Result, len(w.Members))
+	for i, member := range w.Members {
+		fmt.Println(headerStyle.Render("SERVER " + member))
+		results[i].Member = member
+
+		ps, err := memberPlugstep(args, member, w.Dir(member))
+		if err != nil {
+			log.Error("Failed to load member", "server", member, "err", err)
+			results[i].Err = err
+			continue
+		}
+		ps.Options = options
+		ps.Options.Member = member
+		results[i].Err = PlanCommand(ps)
+	}
+	return workspaceError(results)
+}
+
+func printWorkspaceSummary(results []workspace.Result) {
+	fmt.Fprintln(os.Stderr, headerStyle.Render(fmt.Sprintf("WORKSPACE (%d)", len(results))))
+	for _, r := range results {
+		status := planUpToDateStyle.Render("OK")
+		detail := ""
+		switch {
+		case r.Err != nil && exitcode.Of(r.Err) == exitcode.Partial:
+			status = planDownloadStyle.Render("PARTIAL")
+			detail = r.Err.Error()
+		case r.Err != nil:
+			status = planFailedStyle.Render("FAILED")
+			detail = r.Err.Error()
+		}
+		fmt.Fprintf(os.Stderr, "  %s %s %s %s %s\n",
+			arrowStyle.Render("→"),
+			nameStyle.Width(25).Render(r.Member),
+			versionStyle.Width(10).Render(r.Duration.Round(100*time.Millisecond).String()),
+			status,
+			descStyle.Render(detail),
+		)
+	}
+}
+
+// workspaceError combines the errors of the members. When only some members
+// failed the install is partial, like a server where only some plugins failed.
+func workspaceError(results []workspace.Result) error {
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Member, r.Err))
+		}
+	}
+
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(errs) < len(results):
+		return exitcode.Wrap(exitcode.Partial, fmt.Errorf("%d of %d servers failed: %w", len(errs), len(results), errors.Join(errs...)))
+	}
+	return errors.Join(errs...)
+}
+
+// memberPlugins is one member in the output of `plugin list --all --json`.
+type memberPlugins struct {
+	Member  string                `json:"member"`
+	Plugins []output.PluginReport `json:"plugins"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// PluginListAllCommand lists the plugins of every member of the workspace.
+func PluginListAllCommand(w *workspace.Workspace, jsonOutput bool) error {
+	if !jsonOutput {
+		results := make([]workspace.Result, len(w.Members))
+		for i, member := range w.Members {
+			fmt.Println(headerStyle.Render("SERVER " + member))
+			results[i] = workspace.Result{Member: member, Err: pluginList(w.Dir(member), false)}
+			if results[i].Err != nil {
+				log.Error("Failed to list plugins", "server", member, "err", results[i].Err)
+			}
+		}
+		return workspaceError(results)
+	}
+
+	members := make([]memberPlugins, len(w.Members))
+	results := memberRunner(w).Run(func(member, dir string) error {
+		i := slices.Index(w.Members, member)
+		members[i] = memberPlugins{Member: member, Plugins: []output.PluginReport{}}
+
+		cfg, _, err := loadConfig(dir)
+		if err == nil {
+			var reports []output.PluginReport
+			if reports, err = pluginReports(cfg, dir); err == nil {
+				members[i].Plugins = reports
+			}
+		}
+		if err != nil {
+			members[i].Error = err.Error()
+		}
+		return err
+	})
+
+	if err := printJSON(members); err != nil {
+		return err
+	}
+	return workspaceError(results)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCacheTTL is how long API responses that change, e.g. the latest
+// version of a plugin, are used before they are revalidated.
+const DefaultCacheTTL = 15 * time.Minute
+
+// CacheNamespaces are the cache namespaces whose TTL [cache.namespaces] can
+// set: plugins for the plugin sources and server for the server jar vendors.
+var CacheNamespaces = []string{"plugins", "server"}
+
+// CacheConfig is the [cache] table.
+type CacheConfig struct {
+	// TTL replaces DefaultCacheTTL for every namespace.
+	TTL string `toml:"ttl"`
+	// Namespaces sets the TTL of single namespaces.
+	Namespaces map[string]string `toml:"namespaces"`
+}
+
+// ParseTTL parses a cache TTL such as "30s", "15m", "2h" or "1d".
+func ParseTTL(s string) (time.Duration, error) {
+	var ttl time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		ttl = time.Duration(n) * 24 * time.Hour
+	} else {
+		ttl, err = time.ParseDuration(s)
+	}
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid TTL %q, use a positive duration such as \"15m\", \"2h\" or \"1d\"", s)
+	}
+	return ttl, nil
+}
+
+// CacheTTL returns the TTL of a cache namespace, falling back to [cache] ttl
+// and then DefaultCacheTTL.
+func (c *PlugstepConfig) CacheTTL(namespace string) time.Duration {
+	return firstTTL(DefaultCacheTTL, c.Cache.Namespaces[namespace], c.Cache.TTL)
+}
+
+// SourceCacheTTL returns the TTL of what a plugin source's API returns,
+// cache_ttl of its [sources] table falling back to the plugins namespace.
+func (c *PlugstepConfig) SourceCacheTTL(source PluginSource) time.Duration {
+	return firstTTL(c.CacheTTL("plugins"), c.Source(source).CacheTTL)
+}
+
+// VendorCacheTTL returns the TTL of what a server jar vendor's API returns,
+// cache_ttl of its [vendors] table falling back to the server namespace.
+func (c *PlugstepConfig) VendorCacheTTL(vendor ServerJarVendor) time.Duration {
+	return firstTTL(c.CacheTTL("server"), c.Vendors[string(vendor)].CacheTTL)
+}
+
+// firstTTL returns the first of ttls that is set, or fallback. Invalid values
+// are reported by Validate and skipped here.
+func firstTTL(fallback time.Duration, ttls ...string) time.Duration {
+	for _, s := range ttls {
+		if s == "" {
+			continue
+		}
+		if ttl, err := ParseTTL(s); err == nil {
+			return ttl
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,556 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Document is plugstep.toml as text. It makes surgical edits so comments, key
+// order and formatting of everything it doesn't touch survive unchanged.
+type Document struct {
+	lines []string
+}
+
+// KeyValue is a key and the value it is set to. Values may be strings,
+// booleans, integers or string slices.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// header is a [table] or [[array]] line.
+type header struct {
+	line  int
+	name  string
+	array bool
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// NewDocument returns an empty document.
+func NewDocument() *Document {
+	return &Document{}
+}
+
+// ParseDocument wraps the contents of a TOML file. It doesn't validate it;
+// decode the same bytes for that.
+func ParseDocument(data []byte) *Document {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return &Document{}
+	}
+	return &Document{lines: strings.Split(text, "\n")}
+}
+
+// LoadDocument reads the TOML file at path.
+func LoadDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(data), nil
+}
+
+// Bytes returns the document with a trailing newline.
+func (d *Document) Bytes() []byte {
+	if len(d.lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(d.lines, "\n") + "\n")
+}
+
+// Save writes the document to path, keeping the file mode of an existing file.
+func (d *Document) Save(path string) error {
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(path); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	return os.WriteFile(path, d.Bytes(), mode)
+}
+
+// Set sets key in a table to value, replacing just the value if the key
+// exists and adding a line after the table's last key otherwise. index selects
+// the entry of an array of tables and is ignored for plain tables. An empty
+// table name addresses the top level keys.
+func (d *Document) Set(table string, index int, key string, value interface{}) error {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	start, end, ok := d.section(table, index)
+	if !ok {
+		if index > 0 {
+			return fmt.Errorf("no [[%s]] entry at index %d", table, index)
+		}
+		return d.AppendTable(table, false, []KeyValue{{Key: key, Value: value}})
+	}
+
+	continuation := d.continuations()
+	lastKey := -1
+	for i := start; i < end; i++ {
+		if continuation[i] {
+			continue
+		}
+		k, valueStart, ok := parseKey(d.lines[i])
+		if !ok {
+			continue
+		}
+		lastKey = i
+		if k != key {
+			continue
+		}
+
+		valueEnd, lineEnd := scanValue(d.lines, i, valueStart)
+		if lineEnd == i {
+			line := d.lines[i]
+			d.lines[i] = line[:valueStart] + encoded + line[valueEnd:]
+			return nil
+		}
+		// Multi-line values are collapsed onto the key's line.
+		d.lines[i] = d.lines[i][:valueStart] + encoded
+		d.lines = append(d.lines[:i+1], d.lines[lineEnd+1:]...)
+		return nil
+	}
+
+	indent := ""
+	insertAt := start
+	switch {
+	case lastKey >= 0:
+		indent = leadingSpace(d.lines[lastKey])
+		insertAt = endOfValue(d.lines, lastKey) + 1
+	case table != "":
+		insertAt = start + 1
+	}
+	d.insert(insertAt, indent+formatKey(key)+" = "+encoded)
+	return nil
+}
+
+// Raw returns the value of key in a table as written, with quotes and
+// variables left as they are. It reports false when the key isn't set.
+func (d *Document) Raw(table string, index int, key string) (string, bool) {
+	start, end, ok := d.section(table, index)
+	if !ok {
+		return "", false
+	}
+	continuation := d.continuations()
+	for i := start; i < end; i++ {
+		if continuation[i] {
+			continue
+		}
+		if k, valueStart, ok := parseKey(d.lines[i]); ok && k == key {
+			valueEnd, lineEnd := scanValue(d.lines, i, valueStart)
+			if lineEnd == i {
+				return strings.TrimSpace(d.lines[i][valueStart:valueEnd]), true
+			}
+			lines := append([]string{d.lines[i][valueStart:]}, d.lines[i+1:lineEnd]...)
+			return strings.Join(append(lines, d.lines[lineEnd][:valueEnd]), "\n"), true
+		}
+	}
+	return "", false
+}
+
+// AppendTable adds a table with the given keys. Entries of an existing array
+// of tables are appended after its last entry, anything else at the end.
+func (d *Document) AppendTable(name string, array bool, values []KeyValue) error {
+	block := []string{}
+	if array {
+		block = append(block, "[["+name+"]]")
+	} else if name != "" {
+		block = append(block, "["+name+"]")
+	}
+	indent := d.tableIndent()
+	for _, kv := range values {
+		encoded, err := encodeValue(kv.Value)
+		if err != nil {
+			return err
+		}
+		block = append(block, indent+formatKey(kv.Key)+" = "+encoded)
+	}
+
+	if array {
+		d.removeEmptyInlineArray(name)
+	}
+
+	insertAt := len(d.lines)
+	if array {
+		headers := d.headers()
+		for i, h := range headers {
+			if h.array && h.name == name {
+				insertAt = len(d.lines)
+				if i+1 < len(headers) {
+					insertAt = d.blockStart(headers[i+1].line)
+				}
+			}
+		}
+	}
+
+	// Keep exactly one blank line between the new table and its neighbours.
+	before := insertAt
+	for before > 0 && strings.TrimSpace(d.lines[before-1]) == "" {
+		before--
+	}
+	after := insertAt
+	for after < len(d.lines) && strings.TrimSpace(d.lines[after]) == "" {
+		after++
+	}
+
+	var replacement []string
+	if before > 0 {
+		replacement = append(replacement, "")
+	}
+	replacement = append(replacement, block...)
+	if after < len(d.lines) {
+		replacement = append(replacement, "")
+	}
+
+	lines := append([]string{}, d.lines[:before]...)
+	lines = append(lines, replacement...)
+	d.lines = append(lines, d.lines[after:]...)
+	return nil
+}
+
+// RemoveTable deletes a table together with the comments directly above its
+// header. index selects the entry of an array of tables.
+func (d *Document) RemoveTable(name string, index int) error {
+	headers := d.headers()
+	n := 0
+	for i, h := range headers {
+		if h.name != name {
+			continue
+		}
+		if h.array && n != index {
+			n++
+			continue
+		}
+
+		start := d.blockStart(h.line)
+		end := len(d.lines)
+		if i+1 < len(headers) {
+			end = d.blockStart(headers[i+1].line)
+		}
+
+		d.lines = append(d.lines[:start], d.lines[end:]...)
+
+		// Drop blank lines left dangling at the end of the file.
+		for len(d.lines) > 0 && strings.TrimSpace(d.lines[len(d.lines)-1]) == "" {
+			d.lines = d.lines[:len(d.lines)-1]
+		}
+		return nil
+	}
+	return fmt.Errorf("no [[%s]] entry at index %d", name, index)
+}
+
+// section returns the line range of a table's keys: from its header (or the
+// start of the file for the top level) up to the next header.
+func (d *Document) section(table string, index int) (int, int, bool) {
+	headers := d.headers()
+	if table == "" {
+		end := len(d.lines)
+		if len(headers) > 0 {
+			end = d.blockStart(headers[0].line)
+		}
+		return 0, end, true
+	}
+
+	n := 0
+	for i, h := range headers {
+		if h.name != table {
+			continue
+		}
+		if h.array && n != index {
+			n++
+			continue
+		}
+		end := len(d.lines)
+		if i+1 < len(headers) {
+			end = headers[i+1].line
+		}
+		return h.line, end, true
+	}
+	return 0, 0, false
+}
+
+func (d *Document) headers() []header {
+	var headers []header
+	continuation := d.continuations()
+	for i, line := range d.lines {
+		if continuation[i] {
+			continue
+		}
+		if h, ok := parseHeader(line); ok {
+			h.line = i
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+// tableIndent returns the indentation used for keys inside tables, taken from
+// the first key after a header.
+func (d *Document) tableIndent() string {
+	headers := d.headers()
+	if len(headers) == 0 {
+		return ""
+	}
+	continuation := d.continuations()
+	for i := headers[0].line + 1; i < len(d.lines); i++ {
+		if continuation[i] {
+			continue
+		}
+		if _, _, ok := parseKey(d.lines[i]); ok {
+			return leadingSpace(d.lines[i])
+		}
+	}
+	return ""
+}
+
+// blockStart moves up from a header over the comment lines attached to it.
+func (d *Document) blockStart(line int) int {
+	for line > 0 && strings.HasPrefix(strings.TrimSpace(d.lines[line-1]), "#") {
+		line--
+	}
+	return line
+}
+
+func (d *Document) insert(at int, line string) {
+	d.lines = append(d.lines, "")
+	copy(d.lines[at+1:], d.lines[at:])
+	d.lines[at] = line
+}
+
+// removeEmptyInlineArray drops a top level `name = []`, which would conflict
+// with [[name]] tables.
+func (d *Document) removeEmptyInlineArray(name string) {
+	_, end, _ := d.section("", 0)
+	for i := 0; i < end; i++ {
+		key, valueStart, ok := parseKey(d.lines[i])
+		if ok && key == name && strings.ReplaceAll(stripComment(d.lines[i][valueStart:]), " ", "") == "[]" {
+			d.lines = append(d.lines[:i], d.lines[i+1:]...)
+			// Don't leave a blank line where the key stood alone.
+			if i < len(d.lines) && strings.TrimSpace(d.lines[i]) == "" && (i == 0 || strings.TrimSpace(d.lines[i-1]) == "") {
+				d.lines = append(d.lines[:i], d.lines[i+1:]...)
+			}
+			return
+		}
+	}
+}
+
+// continuations reports for every line whether it starts inside a multi-line
+// string or array, where brackets and key-like text mean nothing.
+func (d *Document) continuations() []bool {
+	result := make([]bool, len(d.lines))
+	var s scanner
+	for i, line := range d.lines {
+		result[i] = s.open()
+		s.scan(line)
+	}
+	return result
+}
+
+// endOfValue returns the last line of the value starting on line i.
+func endOfValue(lines []string, i int) int {
+	_, valueStart, ok := parseKey(lines[i])
+	if !ok {
+		return i
+	}
+	_, end := scanValue(lines, i, valueStart)
+	return end
+}
+
+func parseHeader(line string) (header, bool) {
+	trimmed := strings.TrimSpace(stripComment(line))
+	switch {
+	case strings.HasPrefix(trimmed, "[[") && strings.HasSuffix(trimmed, "]]"):
+		return header{name: strings.TrimSpace(trimmed[2 : len(trimmed)-2]), array: true}, true
+	case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+		return header{name: strings.TrimSpace(trimmed[1 : len(trimmed)-1])}, true
+	}
+	return header{}, false
+}
+
+// parseKey splits `key = value` lines, returning the key and the offset of
+// the value. Dotted keys aren't supported and never match.
+func parseKey(line string) (string, int, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '[' {
+		return "", 0, false
+	}
+
+	eq := strings.Index(line, "=")
+	if eq < 0 {
+		return "", 0, false
+	}
+	key := strings.TrimSpace(line[:eq])
+	if unquoted, err := strconv.Unquote(key); err == nil && strings.HasPrefix(key, `"`) {
+		key = unquoted
+	} else if strings.HasPrefix(key, "'") && strings.HasSuffix(key, "'") && len(key) > 1 {
+		key = key[1 : len(key)-1]
+	} else if !bareKey.MatchString(key) {
+		return "", 0, false
+	}
+
+	valueStart := eq + 1
+	for valueStart < len(line) && (line[valueStart] == ' ' || line[valueStart] == '\t') {
+		valueStart++
+	}
+	return key, valueStart, true
+}
+
+// scanValue finds where the value starting at lines[line][col] ends, returning
+// the end offset on the last line and the last line's index.
+func scanValue(lines []string, line int, col int) (int, int) {
+	var s scanner
+	end := s.scan(lines[line][col:]) + col
+	for s.open() && line+1 < len(lines) {
+		line++
+		end = s.scan(lines[line])
+	}
+	return end, line
+}
+
+func stripComment(line string) string {
+	var s scanner
+	return line[:s.scan(line)]
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// scanner tracks strings and brackets across lines.
+type scanner struct {
+	multiBasic   bool
+	multiLiteral bool
+	depth        int
+}
+
+func (s *scanner) open() bool {
+	return s.multiBasic || s.multiLiteral || s.depth > 0
+}
+
+// scan consumes one line and returns the offset where content ends, i.e. the
+// start of a trailing comment with the whitespace before it trimmed.
+func (s *scanner) scan(line string) int {
+	end := len(line)
+	i := 0
+	for i < len(line) {
+		switch {
+		case s.multiBasic:
+			if line[i] == '\\' {
+				i += 2
+				continue
+			}
+			if strings.HasPrefix(line[i:], `"""`) {
+				s.multiBasic = false
+				i += 3
+				continue
+			}
+			i++
+		case s.multiLiteral:
+			if strings.HasPrefix(line[i:], "'''") {
+				s.multiLiteral = false
+				i += 3
+				continue
+			}
+			i++
+		case line[i] == '#':
+			end = i
+			i = len(line)
+		case strings.HasPrefix(line[i:], `"""`):
+			s.multiBasic = true
+			i += 3
+		case strings.HasPrefix(line[i:], "'''"):
+			s.multiLiteral = true
+			i += 3
+		case line[i] == '"':
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+		case line[i] == '\'':
+			i++
+			for i < len(line) && line[i] != '\'' {
+				i++
+			}
+			i++
+		case line[i] == '[' || line[i] == '{':
+			s.depth++
+			i++
+		case line[i] == ']' || line[i] == '}':
+			if s.depth > 0 {
+				s.depth--
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	return len(strings.TrimRight(line[:end], " \t"))
+}
+
+func formatKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return quote(key)
+}
+
+func encodeValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = quote(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+	return "", fmt.Errorf("unsupported TOML value type %T", value)
+}
+
+// quote encodes s as a TOML basic string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+)
+
+// Default API URLs, replaced by base_url in [sources.<name>] and
+// [vendors.<name>].
+const (
+	DefaultModrinthURL = "https://api.modrinth.com/v2"
+	DefaultHangarURL   = "https://hangar.papermc.io/api/v1"
+	DefaultPaperMCURL  = "https://fill.papermc.io"
+)
+
+var defaultURLs = map[string]string{
+	string(PluginSourceModrinth):    DefaultModrinthURL,
+	string(PluginSourcePaperHangar): DefaultHangarURL,
+	string(ServerJarVendorPaperMC):  DefaultPaperMCURL,
+}
+
+// SourceEndpoint returns the API of a plugin source, for custom plugins the
+// URL relative download URLs are resolved against.
+func (c *PlugstepConfig) SourceEndpoint(source PluginSource) utils.Endpoint {
+	s := c.Source(source)
+	return endpoint(string(source), s.BaseURL, s.Mirrors)
+}
+
+// VendorEndpoint returns the API of a server jar vendor.
+func (c *PlugstepConfig) VendorEndpoint(vendor ServerJarVendor) utils.Endpoint {
+	v := c.Vendors[string(vendor)]
+	return endpoint(string(vendor), v.BaseURL, v.Mirrors)
+}
+
+// DefaultEndpoint returns the API of a source or vendor without a config,
+// e.g. while setting up a new server.
+func DefaultEndpoint(name string) utils.Endpoint {
+	return endpoint(name, "", nil)
+}
+
+// EndpointEnv returns the variables overriding the URL and the mirrors of a
+// source or vendor, e.g. PLUGSTEP_PAPER_HANGAR_URL and
+// PLUGSTEP_PAPER_HANGAR_MIRRORS.
+func EndpointEnv(name string) (string, string) {
+	prefix := "PLUGSTEP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	return prefix + "_URL", prefix + "_MIRRORS"
+}
+
+// endpoint applies the defaults and the environment to a configured API. The
+// variables win over the config, so CI can point a checked in config at a
+// mirror or a local stub.
+func endpoint(name, baseURL string, mirrors []string) utils.Endpoint {
+	urlVar, mirrorsVar := EndpointEnv(name)
+	if value := os.Getenv(urlVar); value != "" {
+		baseURL = value
+	}
+	if value, ok := os.LookupEnv(mirrorsVar); ok {
+		mirrors = nil
+		for _, mirror := range strings.Split(value, ",") {
+			if mirror = strings.TrimSpace(mirror); mirror != "" {
+				mirrors = append(mirrors, mirror)
+			}
+		}
+	}
+
+	if baseURL == "" {
+		baseURL = defaultURLs[name]
+	}
+	return utils.Endpoint{URL: baseURL, Mirrors: mirrors}
+}
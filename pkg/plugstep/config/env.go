@@ -0,0 +1,322 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+)
+
+// DotEnvFile is the file next to plugstep.toml that can define variables for
+// interpolation, e.g. tokens that shouldn't be committed.
+const DotEnvFile = ".env"
+
+var (
+	// secretName matches the variables and keys whose values are redacted
+	// from logs, and the keys and headers whose literal values are flagged by
+	// lint.
+	secretName = regexp.MustCompile(`(?i)token|secret|passw|key|auth|credential`)
+	// secretParam matches URL query parameters carrying credentials.
+	secretParam = regexp.MustCompile(`(?i)^(access_?token|token|api_?key|key|secret|password|auth|sig|signature)$`)
+	// secretPrefix matches well known personal access token formats.
+	secretPrefix = regexp.MustCompile(`^(ghp_|gho_|github_pat_|glpat-|mrp_|Bearer )`)
+)
+
+// environment resolves the variables of ${VAR} references: the process
+// environment first, then the .env file.
+type environment struct {
+	dotenv map[string]string
+}
+
+// loadEnvironment reads the .env file in dir, if there is one.
+func loadEnvironment(dir string) (*environment, error) {
+	env := &environment{dotenv: map[string]string{}}
+
+	path := filepath.Join(dir, DotEnvFile)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return env, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, line)
+		}
+		env.dotenv[name] = unquoteEnv(strings.TrimSpace(value))
+	}
+	return env, scanner.Err()
+}
+
+func unquoteEnv(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		return strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+func (e *environment) lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	value, ok := e.dotenv[name]
+	return value, ok
+}
+
+// expand replaces ${VAR} and ${VAR:-default} in s. $${ is a literal ${. A
+// variable that isn't set and has no default is an error.
+func (e *environment) expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if start > 0 && s[start-1] == '$' {
+			b.WriteString(s[:start-1] + "${")
+			s = s[start+2:]
+			continue
+		}
+
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+		reference := s[start+2 : start+end]
+		name, fallback, hasFallback := strings.Cut(reference, ":-")
+		if name == "" {
+			return "", fmt.Errorf("empty variable name in %q", s)
+		}
+
+		value, ok := e.lookup(name)
+		if !ok || (value == "" && hasFallback) {
+			if !hasFallback {
+				return "", fmt.Errorf("variable %s is not set, define it in the environment or %s", name, DotEnvFile)
+			}
+			value = fallback
+		}
+		if ok && value != "" && secretName.MatchString(name) {
+			utils.RegisterSecret(value)
+		}
+
+		b.WriteString(s[:start] + value)
+		s = s[start+end+1:]
+	}
+}
+
+// valueLocation is where a string value sits in plugstep.toml.
+type valueLocation struct {
+	table string
+	// index is the position in an array of tables, see array.
+	index int
+	array bool
+	key   string
+}
+
+func (l valueLocation) String() string {
+	table := l.table
+	if l.array {
+		table = fmt.Sprintf("%s[%d]", table, l.index)
+	}
+	if table == "" {
+		return l.key
+	}
+	return table + "." + l.key
+}
+
+// interpolate expands the variable references in every string of v, a
+// pointer to a config struct. It returns the error of every value that
+// couldn't be expanded and the locations of literal values that look like
+// credentials.
+func (e *environment) interpolate(v interface{}) (errs []locatedError, literalSecrets []valueLocation) {
+	walkStrings(reflect.ValueOf(v), valueLocation{}, func(at valueLocation, s string) string {
+		if !strings.Contains(s, "${") && looksLikeSecret(at.key, s) {
+			literalSecrets = append(literalSecrets, at)
+		}
+		expanded, err := e.expand(s)
+		if err != nil {
+			errs = append(errs, locatedError{at: at, err: err})
+			return s
+		}
+		registerSecrets(at, expanded)
+		return expanded
+	})
+	return errs, literalSecrets
+}
+
+// registerSecrets redacts the credentials in a value from the log, whatever
+// the variables they came from are called: the whole value of keys named like
+// credentials and of headers, and the password and credential parameters of
+// URLs.
+func registerSecrets(at valueLocation, value string) {
+	if secretName.MatchString(at.key) || at.table == "headers" || strings.HasSuffix(at.table, ".headers") {
+		utils.RegisterSecret(value)
+		return
+	}
+
+	if !isURL(value) {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return
+	}
+	if password, ok := u.User.Password(); ok {
+		utils.RegisterSecret(password)
+	}
+	for param, values := range u.Query() {
+		if secretParam.MatchString(param) {
+			for _, v := range values {
+				utils.RegisterSecret(v)
+			}
+		}
+	}
+}
+
+// interpolate expands the variables in the config, returning the first value
+// that couldn't be expanded.
+func (c *PlugstepConfig) interpolate(env *environment) error {
+	errs, literalSecrets := env.interpolate(c)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	for _, at := range literalSecrets {
+		c.literalSecrets = append(c.literalSecrets, at.String())
+	}
+	return nil
+}
+
+type locatedError struct {
+	at  valueLocation
+	err error
+}
+
+func (e locatedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.at, e.err)
+}
+
+// walkStrings calls visit for every string reachable through the toml fields
+// of v and stores what it returns.
+func walkStrings(v reflect.Value, at valueLocation, visit func(at valueLocation, s string) string) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), at, visit)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(visit(at, v.String()))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			element := at
+			if v.Type().Elem().Kind() == reflect.Struct {
+				element.index = i
+				element.array = true
+			}
+			walkStrings(v.Index(i), element, visit)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			// Map values can't be set in place, so they are copied and stored back.
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			entry := valueLocation{table: at.table, key: iter.Key().String()}
+			if value.Kind() == reflect.Struct {
+				entry = valueLocation{table: childTable(at, formatKey(iter.Key().String()))}
+			}
+			walkStrings(value, entry, visit)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+
+			field := valueLocation{table: at.table, index: at.index, array: at.array, key: name}
+			fieldType := t.Field(i).Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			switch {
+			case fieldType.Kind() == reflect.Struct, fieldType.Kind() == reflect.Map,
+				fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct:
+				// Nested tables start a new location.
+				field = valueLocation{table: childTable(at, name)}
+			}
+			walkStrings(v.Field(i), field, visit)
+		}
+	}
+}
+
+// childTable returns the dotted name of a table nested in the one at at. Maps
+// of tables, e.g. [profiles.<name>], are addressed through their key.
+func childTable(at valueLocation, name string) string {
+	if at.table == "" {
+		return name
+	}
+	return at.table + "." + name
+}
+
+// looksLikeSecret reports whether a literal value is probably a credential:
+// a key named like one, a URL with a password or token parameter, or a
+// well-known token format.
+func looksLikeSecret(key, value string) bool {
+	if value == "" {
+		return false
+	}
+	if secretPrefix.MatchString(value) {
+		return true
+	}
+	if secretName.MatchString(key) {
+		return true
+	}
+
+	if !isURL(value) {
+		return false
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	if _, ok := u.User.Password(); ok {
+		return true
+	}
+	for param := range u.Query() {
+		if secretParam.MatchString(param) {
+			return true
+		}
+	}
+	return false
+}
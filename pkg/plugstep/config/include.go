@@ -0,0 +1,317 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+	"github.com/BurntSushi/toml"
+)
+
+// includeFile is what an included file may define: plugins and further
+// includes.
+type includeFile struct {
+	Include []string       `toml:"include"`
+	Plugins []PluginConfig `toml:"plugins"`
+}
+
+// resolveIncludes merges the plugins of the files listed in include into the
+// config. A file's own plugins take precedence over the ones it includes;
+// included files that define the same plugin differently are a conflict.
+// Relative paths are resolved against the including file, variables in
+// included files are expanded from env. Invalid included plugins are
+// reported as a *ValidationError positioned in the file that defines them.
+func (c *PlugstepConfig) resolveIncludes(configLocation string, env *environment) error {
+	if len(c.Include) == 0 {
+		return nil
+	}
+
+	location, err := filepath.Abs(configLocation)
+	if err != nil {
+		return err
+	}
+	r := &includeResolver{
+		root: filepath.Dir(location),
+		dir:  filepath.Dir(configLocation),
+		seen: map[string]bool{location: true},
+		env:  env,
+	}
+
+	included, err := r.loadAll(c.Include, filepath.Dir(location))
+	if err != nil {
+		return err
+	}
+	if len(r.diagnostics) > 0 {
+		return &ValidationError{Diagnostics: r.diagnostics}
+	}
+	c.Plugins = withPrecedence(c.Plugins, included)
+	c.literalSecrets = append(c.literalSecrets, r.literalSecrets...)
+	c.remoteIncludes = r.remote
+	return nil
+}
+
+// RemoteInclude is an included URL and the content its hash pins.
+type RemoteInclude struct {
+	URL          string
+	ChecksumType string
+	Checksum     string
+	Data         []byte
+}
+
+// RemoteIncludes returns the URLs the config includes, directly or through
+// other includes.
+func (c *PlugstepConfig) RemoteIncludes() []RemoteInclude {
+	return c.remoteIncludes
+}
+
+// CacheInclude stores the content of a remote include pinned by the checksum,
+// so that it is read without a request, e.g. offline. It must match the
+// checksum.
+func CacheInclude(checksumType, checksum string, data []byte) error {
+	if actual := includeChecksum(checksumType, data); actual != checksum {
+		return fmt.Errorf("%s mismatch: expected %s, got %s", checksumType, checksum, actual)
+	}
+	cache := utils.InitCache("includes")
+	if cache == nil {
+		return fmt.Errorf("the cache isn't available")
+	}
+	cache.SetPermanent(checksumType+":"+checksum, string(data))
+	return nil
+}
+
+type includeResolver struct {
+	// root is the directory of plugstep.toml, which IncludedFrom is relative to.
+	root string
+	// dir is the directory of plugstep.toml as given, which diagnostics are
+	// relative to.
+	dir string
+	// seen holds the files on the current include chain, to detect cycles.
+	seen map[string]bool
+	env  *environment
+	// literalSecrets collects the credentials committed in included files.
+	literalSecrets []string
+	// remote collects the included URLs.
+	remote []RemoteInclude
+	// diagnostics collects the problems of the included plugins.
+	diagnostics []Diagnostic
+}
+
+func (r *includeResolver) loadAll(includes []string, base string) ([]PluginConfig, error) {
+	var merged []PluginConfig
+	for _, include := range includes {
+		plugins, err := r.load(include, base)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range plugins {
+			i := indexOfPlugin(merged, p)
+			if i < 0 {
+				merged = append(merged, p)
+				continue
+			}
+			if !sameDefinition(merged[i], p) {
+				return nil, fmt.Errorf("plugin %s is defined differently in %s and %s, define it in plugstep.toml to choose one", *p.Resource, merged[i].IncludedFrom, p.IncludedFrom)
+			}
+		}
+	}
+	return merged, nil
+}
+
+func (r *includeResolver) load(include string, base string) ([]PluginConfig, error) {
+	location := resolveIncludeLocation(include, base)
+	if r.seen[location] {
+		return nil, fmt.Errorf("include cycle: %s includes itself", r.display(location))
+	}
+	r.seen[location] = true
+	defer delete(r.seen, location)
+
+	data, err := readInclude(include, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read include %s: %w", include, err)
+	}
+	if isURL(location) {
+		_, fragment, _ := strings.Cut(include, "#")
+		checksumType, digest, _ := ParseChecksum(strings.Replace(fragment, "=", ":", 1))
+		r.remote = append(r.remote, RemoteInclude{URL: location, ChecksumType: checksumType, Checksum: digest, Data: data})
+	}
+
+	var file includeFile
+	md, err := toml.Decode(string(data), &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse include %s: %w", r.display(location), err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("include %s defines %q, included files may only define include and [[plugins]]", r.display(location), undecoded[0].String())
+	}
+
+	errs, literalSecrets := r.env.interpolate(&file)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("include %s: %w", r.display(location), errs[0])
+	}
+	for _, at := range literalSecrets {
+		r.literalSecrets = append(r.literalSecrets, r.display(location)+": "+at.String())
+	}
+
+	path := location
+	if !isURL(location) {
+		path = filepath.Join(r.dir, r.display(location))
+	}
+	v := &validator{path: path, doc: ParseDocument(data), failed: map[valueLocation]bool{}}
+	var plugins []PluginConfig
+	for i, p := range file.Plugins {
+		reported := len(v.diagnostics)
+		v.plugin("plugins", i, p)
+		if len(v.diagnostics) > reported {
+			// Invalid plugins can't be merged, the diagnostics fail the load.
+			continue
+		}
+		p.IncludedFrom = r.display(location)
+		plugins = append(plugins, p)
+	}
+	r.diagnostics = append(r.diagnostics, v.diagnostics...)
+
+	nested, err := r.loadAll(file.Include, includeBase(location))
+	if err != nil {
+		return nil, err
+	}
+	return withPrecedence(plugins, nested), nil
+}
+
+// display shortens local includes to a path relative to plugstep.toml.
+func (r *includeResolver) display(location string) string {
+	if isURL(location) {
+		return location
+	}
+	if rel, err := filepath.Rel(r.root, location); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return location
+}
+
+// resolveIncludeLocation returns the absolute path or URL, without its hash,
+// of an include relative to base.
+func resolveIncludeLocation(include string, base string) string {
+	target, _, _ := strings.Cut(include, "#")
+	if isURL(target) {
+		return target
+	}
+	if isURL(base) {
+		baseURL, err := url.Parse(base)
+		if err == nil {
+			if ref, err := url.Parse(target); err == nil {
+				return baseURL.ResolveReference(ref).String()
+			}
+		}
+		return target
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(base, target)
+	}
+	return filepath.Clean(target)
+}
+
+// includeBase returns what includes inside the file at location are relative to.
+func includeBase(location string) string {
+	if isURL(location) {
+		return location
+	}
+	return filepath.Dir(location)
+}
+
+func readInclude(include string, location string) ([]byte, error) {
+	if !isURL(location) {
+		return os.ReadFile(location)
+	}
+
+	_, fragment, _ := strings.Cut(include, "#")
+	checksumType, digest, err := ParseChecksum(strings.Replace(fragment, "=", ":", 1))
+	if fragment == "" || err != nil {
+		return nil, fmt.Errorf("remote includes must pin a hash, e.g. %s#sha256=<hex>", location)
+	}
+
+	cache := utils.InitCache("includes")
+	cacheKey := checksumType + ":" + digest
+	var cached string
+	if cache != nil && cache.Get(cacheKey, &cached) {
+		return []byte(cached), nil
+	}
+
+	if utils.Offline {
+		return nil, fmt.Errorf("%s is %w and isn't cached, read it online once or vendor the bundle again", location, utils.ErrOffline)
+	}
+	r, err := utils.HTTPClient.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("got %d", r.StatusCode)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := includeChecksum(checksumType, data); actual != digest {
+		return nil, fmt.Errorf("%s mismatch: expected %s, got %s", checksumType, digest, actual)
+	}
+
+	if cache != nil {
+		// Pinned by hash, so the content never changes.
+		cache.SetPermanent(cacheKey, string(data))
+	}
+	return data, nil
+}
+
+func includeChecksum(checksumType string, data []byte) string {
+	if checksumType == "sha512" {
+		sum := sha512.Sum512(data)
+		return hex.EncodeToString(sum[:])
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// withPrecedence returns own followed by the included plugins own doesn't
+// define itself.
+func withPrecedence(own []PluginConfig, included []PluginConfig) []PluginConfig {
+	merged := own
+	for _, p := range included {
+		if indexOfPlugin(own, p) < 0 {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+func indexOfPlugin(plugins []PluginConfig, p PluginConfig) int {
+	for i, candidate := range plugins {
+		if samePlugin(candidate, p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameDefinition reports whether two entries of the same plugin install the
+// same thing.
+func sameDefinition(a, b PluginConfig) bool {
+	equal := func(x, y *string) bool {
+		return (x == nil || *x == "") == (y == nil || *y == "") && (x == nil || y == nil || *x == *y)
+	}
+	return equal(a.Version, b.Version) && equal(a.DownloadURL, b.DownloadURL) && equal(a.Checksum, b.Checksum)
+}
@@ -1,10 +1,213 @@
 package config
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Severity ranks lint issues. Only errors make `plugstep lint` fail.
+type Severity string
+
+const (
+	SeverityInfo  Severity = "info"
+	SeverityWarn  Severity = "warn"
+	SeverityError Severity = "error"
+)
+
+// LintIssue is a problem found by a lint rule.
+type LintIssue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Plugin is the resource of the plugin the issue is about, if any.
+	Plugin string `json:"plugin,omitempty"`
+}
+
+// LintRule checks a config for one kind of problem. Check fills in the
+// message and plugin of its issues; LintIssues adds the rule id and the rule's
+// severity unless the issue sets its own.
+type LintRule struct {
+	ID          string
+	Severity    Severity
+	Description string
+	Check       func(c *PlugstepConfig) []LintIssue
+}
+
+// LintConfig is the [lint] table of plugstep.toml.
+type LintConfig struct {
+	// Disable lists the ids of rules that shouldn't run.
+	Disable []string `toml:"disable"`
+}
+
+var lintRules = []LintRule{
+	{
+		ID:          "server-latest",
+		Severity:    SeverityWarn,
+		Description: "the server jar follows the latest build",
+		Check:       lintServerLatest,
+	},
+	{
+		ID:          "unpinned-plugin",
+		Severity:    SeverityWarn,
+		Description: "a plugin has no version, so installs pick up new releases",
+		Check:       lintUnpinnedPlugins,
+	},
+	{
+		ID:          "custom-no-checksum",
+		Severity:    SeverityWarn,
+		Description: "a custom plugin has no checksum to verify the download against",
+		Check:       lintCustomChecksums,
+	},
+	{
+		ID:          "literal-token",
+		Severity:    SeverityWarn,
+		Description: "a token or password is committed in plain text instead of a ${VAR} reference",
+		Check:       lintLiteralTokens,
+	},
+	{
+		ID:          "duplicate-resource",
+		Severity:    SeverityError,
+		Description: "the same plugin is listed more than once",
+		Check:       lintDuplicates,
+	},
+}
+
+// RegisterLintRule adds a rule run by LintIssues. Packages that need more than
+// the config, like the plugin sources, register their rules from init.
+func RegisterLintRule(rule LintRule) {
+	lintRules = append(lintRules, rule)
+}
+
+// LintRules returns every registered rule, sorted by id.
+func LintRules() []LintRule {
+	rules := slices.Clone(lintRules)
+	slices.SortFunc(rules, func(a, b LintRule) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return rules
+}
+
+// LintIssues runs every rule that isn't disabled in [lint] and returns their
+// issues, most severe first.
+func (c *PlugstepConfig) LintIssues() []LintIssue {
+	issues := []LintIssue{}
+
+	known := map[string]bool{}
+	for _, rule := range lintRules {
+		known[rule.ID] = true
+		if slices.Contains(c.LintSettings.Disable, rule.ID) {
+			continue
+		}
+		for _, issue := range rule.Check(c) {
+			issue.Rule = rule.ID
+			if issue.Severity == "" {
+				issue.Severity = rule.Severity
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	for _, id := range c.LintSettings.Disable {
+		if !known[id] {
+			issues = append(issues, LintIssue{
+				Rule:     "lint-config",
+				Severity: SeverityWarn,
+				Message:  fmt.Sprintf("[lint] disables unknown rule %q", id),
+			})
+		}
+	}
+
+	slices.SortStableFunc(issues, func(a, b LintIssue) int {
+		return b.Severity.rank() - a.Severity.rank()
+	})
+	return issues
+}
+
+// Lint returns the messages of every lint issue.
 func (c *PlugstepConfig) Lint() []string {
 	issues := []string{}
+	for _, issue := range c.LintIssues() {
+		issues = append(issues, issue.Message)
+	}
+	return issues
+}
 
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarn:
+		return 1
+	}
+	return 0
+}
+
+func lintServerLatest(c *PlugstepConfig) []LintIssue {
 	if c.Server.Version == "latest" {
-		issues = append(issues, "Using version = latest on server jar, can be good for security, possible API versioning issues")
+		return []LintIssue{{Message: "Using version = latest on server jar, can be good for security, possible API versioning issues"}}
+	}
+	return nil
+}
+
+func lintUnpinnedPlugins(c *PlugstepConfig) []LintIssue {
+	var issues []LintIssue
+	for _, p := range c.Plugins {
+		// Custom plugins are pinned by their download URL.
+		if p.Source == PluginSourceCustom || p.Resource == nil {
+			continue
+		}
+		if p.Version == nil || *p.Version == "" {
+			issues = append(issues, LintIssue{
+				Message: fmt.Sprintf("%s is not pinned, installs will pick up new releases (pin it with `plugstep plugin pin %s`)", *p.Resource, *p.Resource),
+				Plugin:  *p.Resource,
+			})
+		}
+	}
+	return issues
+}
+
+func lintCustomChecksums(c *PlugstepConfig) []LintIssue {
+	var issues []LintIssue
+	for _, p := range c.Plugins {
+		if p.Source != PluginSourceCustom || p.Resource == nil {
+			continue
+		}
+		if p.Checksum == nil || *p.Checksum == "" {
+			issues = append(issues, LintIssue{
+				Message: fmt.Sprintf("%s has no checksum, set checksum = \"sha256:<hex>\" so a tampered download is caught", *p.Resource),
+				Plugin:  *p.Resource,
+			})
+		}
+	}
+	return issues
+}
+
+func lintLiteralTokens(c *PlugstepConfig) []LintIssue {
+	var issues []LintIssue
+	for _, at := range c.literalSecrets {
+		issues = append(issues, LintIssue{
+			Message: fmt.Sprintf("%s looks like a credential in plain text, reference a variable like ${TOKEN} set in the environment or %s instead", at, DotEnvFile),
+		})
+	}
+	return issues
+}
+
+func lintDuplicates(c *PlugstepConfig) []LintIssue {
+	var issues []LintIssue
+	seen := map[string]int{}
+	for _, p := range c.Plugins {
+		if p.Resource == nil {
+			continue
+		}
+		key := strings.ToLower(*p.Resource)
+		seen[key]++
+		if seen[key] == 2 {
+			issues = append(issues, LintIssue{
+				Message: fmt.Sprintf("%s is listed more than once", *p.Resource),
+				Plugin:  *p.Resource,
+			})
+		}
 	}
 	return issues
 }
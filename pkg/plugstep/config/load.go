@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/BurntSushi/toml"
 	"github.com/charmbracelet/log"
@@ -15,9 +16,56 @@ func LoadPlugstepConfig(configLocation string) (*PlugstepConfig, error) {
 		return nil, err
 	}
 
+	env, err := loadEnvironment(filepath.Dir(configLocation))
+	if err != nil {
+		return nil, err
+	}
+	if err := config.interpolate(env); err != nil {
+		return nil, err
+	}
+
 	if config.Server == (ServerConfig{}) {
 		return nil, fmt.Errorf("failed to find server config value in %s", configLocation)
 	}
 
+	if err := config.resolveIncludes(configLocation, env); err != nil {
+		return nil, err
+	}
+
+	if err := config.finish(configLocation); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// Load checks the config at path like Check and loads it like
+// LoadPlugstepConfig, reading every include only once.
+func Load(path string) (*PlugstepConfig, error) {
+	log.Debug("loading config", "configLocation", path)
+
+	diagnostics, config, err := validate(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(diagnostics) > 0 {
+		return nil, &ValidationError{Diagnostics: diagnostics}
+	}
+
+	if config.Server == (ServerConfig{}) {
+		return nil, fmt.Errorf("failed to find server config value in %s", path)
+	}
+	if err := config.finish(path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// finish resolves the paths of a config relative to it and applies the active
+// profile.
+func (c *PlugstepConfig) finish(configLocation string) error {
+	if c.Network.CABundle != "" && !filepath.IsAbs(c.Network.CABundle) {
+		c.Network.CABundle = filepath.Join(filepath.Dir(configLocation), c.Network.CABundle)
+	}
+	return c.ApplyProfile(ActiveProfile())
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ProfileEnv selects the profile when --profile isn't given.
+const ProfileEnv = "PLUGSTEP_PROFILE"
+
+// ProfileConfig is a [profiles.<name>] overlay. Server keys that are set
+// override the base [server]; plugins replace the base plugin with the same
+// source and resource or are added after the base plugins.
+type ProfileConfig struct {
+	Server  ServerConfig   `toml:"server"`
+	Plugins []PluginConfig `toml:"plugins"`
+}
+
+var selectedProfile string
+
+// SelectProfile sets the profile LoadPlugstepConfig merges, overriding
+// PLUGSTEP_PROFILE.
+func SelectProfile(name string) {
+	selectedProfile = name
+}
+
+// ActiveProfile returns the profile selected with SelectProfile or
+// PLUGSTEP_PROFILE, empty for the base config.
+func ActiveProfile() string {
+	if selectedProfile != "" {
+		return selectedProfile
+	}
+	return os.Getenv(ProfileEnv)
+}
+
+// ApplyProfile merges the named profile onto the config. An empty name leaves
+// the config as is.
+func (c *PlugstepConfig) ApplyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := c.ProfileNames()
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q, plugstep.toml defines no [profiles]", name)
+		}
+		return fmt.Errorf("unknown profile %q (defined: %s)", name, strings.Join(names, ", "))
+	}
+
+	if profile.Server.Vendor != "" {
+		c.Server.Vendor = profile.Server.Vendor
+	}
+	if profile.Server.Project != "" {
+		c.Server.Project = profile.Server.Project
+	}
+	if profile.Server.MinecraftVersion != "" {
+		c.Server.MinecraftVersion = profile.Server.MinecraftVersion
+	}
+	if profile.Server.Version != "" {
+		c.Server.Version = profile.Server.Version
+	}
+
+	for i, p := range profile.Plugins {
+		p.Profile = name
+		p.profileIndex = i
+
+		replaced := false
+		for j, base := range c.Plugins {
+			if base.Profile == "" && samePlugin(base, p) {
+				c.Plugins[j] = p
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Plugins = append(c.Plugins, p)
+		}
+	}
+
+	c.Profile = name
+	return nil
+}
+
+// ProfileNames returns the names of the defined profiles, sorted.
+func (c *PlugstepConfig) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// PluginTable returns the array of tables the i-th plugin is defined in and
+// its index there, for editing it with a Document. Plugins from included
+// files aren't in plugstep.toml; check IncludedFrom first.
+func (c *PlugstepConfig) PluginTable(i int) (string, int) {
+	p := c.Plugins[i]
+	if p.Profile == "" {
+		return "plugins", i
+	}
+	return ProfilePluginsTable(p.Profile), p.profileIndex
+}
+
+// ServerTable returns the table that sets a server key: the active profile's
+// server table when it overrides the key, [server] otherwise.
+func (c *PlugstepConfig) ServerTable(key string) string {
+	profile, ok := c.Profiles[c.Profile]
+	if !ok || c.Profile == "" {
+		return "server"
+	}
+	overridden := map[string]bool{
+		"vendor":            profile.Server.Vendor != "",
+		"project":           profile.Server.Project != "",
+		"minecraft_version": profile.Server.MinecraftVersion != "",
+		"version":           profile.Server.Version != "",
+	}
+	if overridden[key] {
+		return "profiles." + formatKey(c.Profile) + ".server"
+	}
+	return "server"
+}
+
+// ProfilePluginsTable returns the name of a profile's plugin array of tables.
+func ProfilePluginsTable(profile string) string {
+	return "profiles." + formatKey(profile) + ".plugins"
+}
+
+func samePlugin(a, b PluginConfig) bool {
+	return a.Source == b.Source && a.Resource != nil && b.Resource != nil && strings.EqualFold(*a.Resource, *b.Resource)
+}
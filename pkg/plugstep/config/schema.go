@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is where the published schema of plugstep.toml can be found.
+const SchemaID = "https://forgejo.perny.dev/mineframe/plugstep/raw/branch/main/plugstep.schema.json"
+
+// schemaDescriptions documents the keys of plugstep.toml in the schema, by
+// dotted path with array tables addressed by their name.
+var schemaDescriptions = map[string]string{
+	"":                         "Plugstep server configuration",
+	"include":                  "Files whose [[plugins]] are merged into this config: paths relative to it, or URLs pinned with #sha256=<hex>. Plugins defined here take precedence.",
+	"server":                   "The server jar to install.",
+	"server.vendor":            "Where the server jar is downloaded from.",
+	"server.project":           "The project of the vendor, e.g. paper, folia or velocity.",
+	"server.minecraft_version": "The Minecraft version to run, e.g. 1.21.8.",
+	"server.version":           "The build to install, a build number or \"latest\".",
+	"backups":                  "Backups of the files replaced by an install.",
+	"backups.enabled":          "Whether installs back up the files they replace. Defaults to true.",
+	"backups.keep":             "How many backups to keep, 0 keeping every backup. Defaults to 5.",
+	"lint":                     "Settings for `plugstep lint`.",
+	"lint.disable":             "Ids of lint rules that shouldn't run, see `plugstep lint --rules`.",
+	"plugins":                  "The plugins to install.",
+	"plugins.source":           "Where the plugin is downloaded from.",
+	"plugins.resource":         "The plugin's project name or slug on its source.",
+	"plugins.version":          "The version to install. Installs the newest version when unset.",
+	"plugins.download_url":     "The URL of the jar, for custom plugins.",
+	"plugins.checksum":         "The checksum of a custom plugin's jar, as sha256:<hex> or sha512:<hex>.",
+	"profiles":                 "Named overlays of the config, selected with --profile or PLUGSTEP_PROFILE.",
+	"profiles.*":               "A profile merged onto the base config when selected.",
+	"profiles.*.server":        "Server keys that override [server]. Unset keys keep the base value.",
+	"profiles.*.plugins":       "Plugins added by the profile. A plugin with the same source and resource as a base plugin replaces it.",
+	"sources":                  "How plugin sources are reached, by source name.",
+	"sources.*":                "Settings of a plugin source. Credentials are only sent to the source's URLs.",
+	"sources.*.base_url":       "Replaces the source's API URL. For custom plugins, relative download_urls are resolved against it.",
+	"sources.*.mirrors":        "API URLs tried in order before base_url, e.g. an internal caching mirror. Not used by custom plugins.",
+	"sources.*.token":          "Sent as the Authorization header, as a bearer token for custom plugins. Use a ${VAR} reference.",
+	"sources.*.api_key":        "A Hangar API key, exchanged for a session token. Use a ${VAR} reference.",
+	"sources.*.username":       "Basic auth user name for custom downloads.",
+	"sources.*.password":       "Basic auth password for custom downloads. Use a ${VAR} reference.",
+	"sources.*.headers":        "Extra HTTP headers sent with every request to the source.",
+	"network":                  "How requests leave the machine, e.g. through a corporate proxy.",
+	"network.proxy":            "Proxy for every request, replacing HTTP_PROXY and HTTPS_PROXY: http://, https:// or socks5:// URL. NO_PROXY still applies.",
+	"network.ca_bundle":        "PEM file of certificates trusted in addition to the system roots, relative to plugstep.toml.",
+	"vendors":                  "How server jar vendor APIs are reached, by vendor name.",
+	"vendors.*":                "Settings of a server jar vendor.",
+	"vendors.*.base_url":       "Replaces the vendor's API URL.",
+	"vendors.*.mirrors":        "API URLs tried in order before base_url, e.g. an internal caching mirror.",
+	"vendors.*.cache_ttl":      "How long the vendor's API responses are used before they are revalidated, e.g. \"1h\". Replaces [cache.namespaces] server.",
+	"sources.*.cache_ttl":      "How long the source's API responses are used before they are revalidated, e.g. \"5m\". Replaces [cache.namespaces] plugins. Not used by custom plugins.",
+	"cache":                    "How long API responses are cached in .plugstep/cache.db. Expired responses are revalidated with their ETag or Last-Modified.",
+	"cache.ttl":                "How long API responses that change, such as the latest version, are used before they are revalidated, e.g. \"15m\", \"2h\" or \"1d\". Defaults to 15m.",
+	"cache.namespaces":         "TTLs of single namespaces, replacing ttl: plugins for the plugin sources, server for the server jar vendors.",
+}
+
+// schemaRequired lists the keys each table must set.
+var schemaRequired = map[string][]string{
+	"":                   {"server"},
+	"server":             {"vendor", "project", "minecraft_version", "version"},
+	"plugins":            {"source", "resource"},
+	"profiles.*.plugins": {"source", "resource"},
+}
+
+// Schema returns a JSON Schema of plugstep.toml generated from the config
+// types, for editors that validate and complete TOML.
+func Schema() map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(PlugstepConfig{}), "")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["$id"] = SchemaID
+	schema["title"] = "plugstep.toml"
+	return schema
+}
+
+// SchemaJSON returns the schema as indented JSON with a trailing newline.
+func SchemaJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(Schema()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func schemaFor(t reflect.Type, path string) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schema := map[string]interface{}{}
+	if description, ok := schemaDescriptions[path]; ok {
+		schema["description"] = description
+	} else if description, ok := schemaDescriptions[strings.TrimPrefix(path, "profiles.*.")]; ok {
+		schema["description"] = description
+	}
+
+	switch t {
+	case reflect.TypeOf(ServerJarVendor("")):
+		schema["type"] = "string"
+		schema["enum"] = SupportedVendors
+		return schema
+	case reflect.TypeOf(PluginSource("")):
+		schema["type"] = "string"
+		schema["enum"] = SupportedSources
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			properties[name] = schemaFor(t.Field(i).Type, strings.TrimPrefix(path+"."+name, "."))
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if required, ok := schemaRequired[path]; ok {
+			schema["required"] = required
+		}
+		if t == reflect.TypeOf(PluginConfig{}) {
+			schema["allOf"] = []interface{}{
+				map[string]interface{}{
+					"if": map[string]interface{}{
+						"properties": map[string]interface{}{"source": map[string]interface{}{"const": PluginSourceCustom}},
+					},
+					"then": map[string]interface{}{"required": []string{"download_url"}},
+				},
+			}
+		}
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = schemaFor(t.Elem(), path+".*")
+		switch path {
+		case "sources":
+			schema["propertyNames"] = map[string]interface{}{"enum": SupportedSources}
+		case "vendors":
+			schema["propertyNames"] = map[string]interface{}{"enum": SupportedVendors}
+		case "cache.namespaces":
+			schema["propertyNames"] = map[string]interface{}{"enum": CacheNamespaces}
+		}
+	case reflect.Slice:
+		schema["type"] = "array"
+		// Array tables share the path of the array for their keys.
+		items := schemaFor(t.Elem(), path)
+		delete(items, "description")
+		schema["items"] = items
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int64:
+		schema["type"] = "integer"
+	}
+
+	if path == "backups.keep" {
+		schema["minimum"] = 0
+	}
+	return schema
+}
@@ -1,8 +1,32 @@
 package config
 
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
 type PlugstepConfig struct {
-	Server  ServerConfig   `toml:"server"`
-	Plugins []PluginConfig `toml:"plugins"`
+	// Include lists files whose plugins are merged into this config, as
+	// paths relative to it or URLs pinned with #sha256=<hex>.
+	Include      []string                 `toml:"include,omitempty"`
+	Server       ServerConfig             `toml:"server"`
+	Backups      BackupConfig             `toml:"backups,omitempty"`
+	LintSettings LintConfig               `toml:"lint,omitempty"`
+	Plugins      []PluginConfig           `toml:"plugins"`
+	Profiles     map[string]ProfileConfig `toml:"profiles,omitempty"`
+	Sources      map[string]SourceConfig  `toml:"sources,omitempty"`
+	Vendors      map[string]VendorConfig  `toml:"vendors,omitempty"`
+	Network      NetworkConfig            `toml:"network,omitempty"`
+	Cache        CacheConfig              `toml:"cache,omitempty"`
+
+	// Profile is the profile merged onto the config, empty for the base config.
+	Profile string `toml:"-"`
+	// literalSecrets lists the values that look like credentials committed
+	// in plain text instead of referencing a variable.
+	literalSecrets []string
+	// remoteIncludes lists the included URLs, with their contents.
+	remoteIncludes []RemoteInclude
 }
 
 type ServerJarVendor string
@@ -33,4 +57,100 @@ type PluginConfig struct {
 	Resource    *string      `toml:"resource"`
 	Version     *string      `toml:"version"`
 	DownloadURL *string      `toml:"download_url"`
+	// Checksum verifies custom downloads, as "sha256:<hex>" or "sha512:<hex>".
+	Checksum *string `toml:"checksum"`
+
+	// Profile is the profile that added or replaced the plugin, empty for
+	// plugins of the base config.
+	Profile string `toml:"-"`
+	// profileIndex is the plugin's index in the profile's plugin list.
+	profileIndex int
+	// IncludedFrom is the included file that defines the plugin, empty for
+	// plugins of plugstep.toml itself.
+	IncludedFrom string `toml:"-"`
+}
+
+// ParseChecksum splits a "sha256:<hex>" or "sha512:<hex>" checksum into its
+// type and lowercase hex digest.
+func ParseChecksum(checksum string) (string, string, error) {
+	checksumType, digest, ok := strings.Cut(checksum, ":")
+	lengths := map[string]int{"sha256": 64, "sha512": 128}
+	length, known := lengths[strings.ToLower(checksumType)]
+	if !ok || !known {
+		return "", "", fmt.Errorf("checksum %q must look like sha256:<hex> or sha512:<hex>", checksum)
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != length {
+		return "", "", fmt.Errorf("checksum %q is not a valid %s digest", checksum, checksumType)
+	}
+	return strings.ToLower(checksumType), strings.ToLower(digest), nil
+}
+
+// SourceConfig is a [sources.<name>] table, configuring how a plugin source
+// is reached. Credentials are only sent to URLs of the source.
+type SourceConfig struct {
+	// BaseURL replaces the source's API URL. For custom plugins it is what
+	// relative download URLs are resolved against.
+	BaseURL string `toml:"base_url"`
+	// Mirrors are API URLs tried in order before BaseURL.
+	Mirrors []string `toml:"mirrors"`
+	// Token is sent as the Authorization header, as a bearer token for custom
+	// plugins.
+	Token string `toml:"token"`
+	// APIKey is a Hangar API key, exchanged for a session token.
+	APIKey string `toml:"api_key"`
+	// Username and Password authenticate custom downloads with basic auth.
+	Username string            `toml:"username"`
+	Password string            `toml:"password"`
+	Headers  map[string]string `toml:"headers"`
+	// CacheTTL replaces the TTL of what the source's API returns.
+	CacheTTL string `toml:"cache_ttl"`
+}
+
+// Source returns the settings of a plugin source, empty when it has no
+// [sources] table.
+func (c *PlugstepConfig) Source(source PluginSource) SourceConfig {
+	return c.Sources[string(source)]
+}
+
+// VendorConfig is a [vendors.<name>] table, configuring how the API of a
+// server jar vendor is reached.
+type VendorConfig struct {
+	// BaseURL replaces the vendor's API URL.
+	BaseURL string `toml:"base_url"`
+	// Mirrors are API URLs tried in order before BaseURL.
+	Mirrors []string `toml:"mirrors"`
+	// CacheTTL replaces the TTL of what the vendor's API returns.
+	CacheTTL string `toml:"cache_ttl"`
+}
+
+// NetworkConfig is the [network] table, configuring how every request
+// leaves the machine.
+type NetworkConfig struct {
+	// Proxy replaces HTTP_PROXY and HTTPS_PROXY, as an http, https or socks5
+	// URL. NO_PROXY still applies.
+	Proxy string `toml:"proxy"`
+	// CABundle is a PEM file trusted in addition to the system roots,
+	// relative to plugstep.toml. LoadPlugstepConfig makes it absolute.
+	CABundle string `toml:"ca_bundle"`
+}
+
+const DefaultBackupKeep = 5
+
+type BackupConfig struct {
+	Enabled *bool `toml:"enabled"`
+	Keep    *int  `toml:"keep"`
+}
+
+// IsEnabled reports whether installs should snapshot replaced files. Backups
+// are on unless explicitly disabled.
+func (b BackupConfig) IsEnabled() bool {
+	return b.Enabled == nil || *b.Enabled
+}
+
+// KeepCount returns how many snapshots to retain, 0 meaning all of them.
+func (b BackupConfig) KeepCount() int {
+	if b.Keep == nil {
+		return DefaultBackupKeep
+	}
+	return *b.Keep
 }
@@ -0,0 +1,552 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Diagnostic is a problem found in plugstep.toml, positioned at the key or
+// table it concerns. Line and Column start at 1.
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// ValidationError carries every diagnostic of an invalid config.
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		lines[i] = d.String()
+	}
+	return fmt.Sprintf("plugstep.toml has %d error(s):\n%s", len(e.Diagnostics), strings.Join(lines, "\n"))
+}
+
+// SupportedVendors and SupportedSources are the values Plugstep can install
+// from. Other declared constants are reserved for future use.
+var (
+	SupportedVendors = []ServerJarVendor{ServerJarVendorPaperMC}
+	SupportedSources = []PluginSource{PluginSourceModrinth, PluginSourcePaperHangar, PluginSourceCustom}
+)
+
+var decodeError = regexp.MustCompile(`^toml: line (\d+) \(last key "([^"]*)"\): (.*)$`)
+
+// Check validates the config at path, returning a *ValidationError listing
+// every problem, or nil if there are none.
+func Check(path string) error {
+	diagnostics, err := Validate(path)
+	if err != nil {
+		return err
+	}
+	if len(diagnostics) > 0 {
+		return &ValidationError{Diagnostics: diagnostics}
+	}
+	return nil
+}
+
+// Validate reports every problem in the config at path: syntax and type
+// errors, unknown keys with suggestions for typos, and missing or invalid
+// values. The error is only set when the file can't be read.
+func Validate(path string) ([]Diagnostic, error) {
+	diagnostics, _, err := validate(path)
+	return diagnostics, err
+}
+
+// validate is Validate, also returning the config with its variables expanded
+// and includes resolved, unless it couldn't be decoded.
+func validate(path string) ([]Diagnostic, *PlugstepConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc := ParseDocument(data)
+
+	var cfg PlugstepConfig
+	md, err := toml.Decode(string(data), &cfg)
+	if err != nil {
+		d := Diagnostic{File: path, Line: 1, Column: 1, Message: err.Error()}
+		var pe toml.ParseError
+		if errors.As(err, &pe) {
+			d.Message = pe.Message
+			if pe.Message == "" {
+				d.Message = strings.TrimPrefix(pe.Error(), fmt.Sprintf("toml: line %d: ", pe.Position.Line))
+			}
+			if pe.LastKey != "" {
+				d.Message = fmt.Sprintf("%s: %s", pe.LastKey, d.Message)
+			}
+			d.Line = max(pe.Position.Line, 1)
+			d.Column = max(pe.Position.Col, 1)
+		} else if m := decodeError.FindStringSubmatch(err.Error()); m != nil {
+			// Type mismatches aren't ParseErrors, only their text has the line.
+			d.Line, _ = strconv.Atoi(m[1])
+			d.Message = fmt.Sprintf("%s: %s", m[2], m[3])
+			if d.Line >= 1 && d.Line <= len(doc.lines) {
+				d.Column = len(leadingSpace(doc.lines[d.Line-1])) + 1
+			}
+		}
+		return []Diagnostic{d}, nil, nil
+	}
+
+	v := &validator{path: path, doc: doc, failed: map[valueLocation]bool{}}
+	v.unknownKeys(md.Undecoded())
+
+	env, err := loadEnvironment(filepath.Dir(path))
+	if err != nil {
+		v.report("", 0, "", "%s", err)
+		env = &environment{dotenv: map[string]string{}}
+	}
+	errs, literalSecrets := env.interpolate(&cfg)
+	for _, at := range literalSecrets {
+		cfg.literalSecrets = append(cfg.literalSecrets, at.String())
+	}
+	for _, e := range errs {
+		index := e.at.index
+		if !e.at.array {
+			index = 0
+		}
+		v.report(e.at.table, index, e.at.key, "%s", e.err)
+		// Checking the value itself would repeat the error.
+		v.failed[valueLocation{table: e.at.table, index: index, key: e.at.key}] = true
+	}
+
+	v.server(cfg.Server, md.IsDefined("server"))
+	for i, p := range cfg.Plugins {
+		v.plugin("plugins", i, p)
+	}
+	for _, name := range cfg.ProfileNames() {
+		profile := cfg.Profiles[name]
+		if profile.Server.Vendor != "" {
+			v.vendor("profiles."+formatKey(name)+".server", profile.Server.Vendor)
+		}
+		for i, p := range profile.Plugins {
+			v.plugin(ProfilePluginsTable(name), i, p)
+		}
+	}
+	var invalid *ValidationError
+	if err := cfg.resolveIncludes(path, env); errors.As(err, &invalid) {
+		v.diagnostics = append(v.diagnostics, invalid.Diagnostics...)
+	} else if err != nil {
+		v.report("", 0, "include", "%s", err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Sources)) {
+		v.source(name, cfg.Sources[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Vendors)) {
+		v.vendorTable(name, cfg.Vendors[name])
+	}
+	v.network(filepath.Dir(path), cfg.Network)
+	v.cache(cfg.Cache)
+	if cfg.Backups.Keep != nil && *cfg.Backups.Keep < 0 {
+		v.report("backups", 0, "keep", "keep must be 0 (keep everything) or more, got %d", *cfg.Backups.Keep)
+	}
+
+	return v.diagnostics, &cfg, nil
+}
+
+type validator struct {
+	path        string
+	doc         *Document
+	diagnostics []Diagnostic
+	// failed holds the values whose variables couldn't be expanded.
+	failed map[valueLocation]bool
+}
+
+// report adds a diagnostic positioned at key, or at the table when the key is
+// missing.
+func (v *validator) report(table string, index int, key string, format string, args ...interface{}) {
+	if v.failed[valueLocation{table: table, index: index, key: key}] {
+		return
+	}
+	line, column := v.doc.locate(table, index, key)
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		File:    v.path,
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) unknownKeys(keys []toml.Key) {
+	for _, key := range keys {
+		name := key[len(key)-1]
+		parts := make([]string, len(key)-1)
+		for i, part := range key[:len(key)-1] {
+			parts[i] = formatKey(part)
+		}
+		table := strings.Join(parts, ".")
+
+		known, ok := knownKeys[tableKind(key[:len(key)-1])]
+		if !ok {
+			// Keys below an unknown table are covered by the table itself.
+			continue
+		}
+
+		message := fmt.Sprintf("unknown key %q", name)
+		if table != "" {
+			message = fmt.Sprintf("unknown key %q in [%s]", name, table)
+		}
+		if suggestion := suggest(name, known); suggestion != "" {
+			message += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+
+		for _, index := range v.doc.indexesWithKey(table, name) {
+			v.report(table, index, name, "%s", message)
+		}
+	}
+}
+
+func (v *validator) server(s ServerConfig, defined bool) {
+	if !defined {
+		v.report("", 0, "", "missing [server] table")
+		return
+	}
+
+	if s.Vendor == "" {
+		v.report("server", 0, "vendor", "missing vendor (supported: %s)", joinValues(SupportedVendors))
+	} else {
+		v.vendor("server", s.Vendor)
+	}
+	if s.Project == "" {
+		v.report("server", 0, "project", "missing project, e.g. \"paper\"")
+	}
+	if s.MinecraftVersion == "" {
+		v.report("server", 0, "minecraft_version", "missing minecraft_version, e.g. \"1.21.8\"")
+	}
+	if s.Version == "" {
+		v.report("server", 0, "version", "missing version, a build number or \"latest\"")
+	}
+}
+
+func (v *validator) vendor(table string, vendor ServerJarVendor) {
+	if contains(SupportedVendors, vendor) {
+		return
+	}
+	message := fmt.Sprintf("unknown vendor %q (supported: %s)", vendor, joinValues(SupportedVendors))
+	if suggestion := suggest(string(vendor), stringValues(SupportedVendors)); suggestion != "" {
+		message = fmt.Sprintf("unknown vendor %q, did you mean %q?", vendor, suggestion)
+	}
+	v.report(table, 0, "vendor", "%s", message)
+}
+
+func (v *validator) plugin(table string, i int, p PluginConfig) {
+	switch {
+	case p.Source == "":
+		v.report(table, i, "source", "missing source (supported: %s)", joinValues(SupportedSources))
+	case !contains(SupportedSources, p.Source):
+		message := fmt.Sprintf("unknown plugin source %q (supported: %s)", p.Source, joinValues(SupportedSources))
+		if suggestion := suggest(string(p.Source), stringValues(SupportedSources)); suggestion != "" {
+			message = fmt.Sprintf("unknown plugin source %q, did you mean %q?", p.Source, suggestion)
+		}
+		v.report(table, i, "source", "%s", message)
+	}
+
+	if p.Resource == nil || *p.Resource == "" {
+		v.report(table, i, "resource", "missing resource, the plugin's project name")
+	}
+	if p.Source == PluginSourceCustom && (p.DownloadURL == nil || *p.DownloadURL == "") {
+		v.report(table, i, "download_url", "custom plugins need a download_url")
+	}
+	if p.Checksum != nil {
+		if p.Source != PluginSourceCustom {
+			v.report(table, i, "checksum", "checksum is only used by custom plugins, %s publishes its own", p.Source)
+		} else if _, _, err := ParseChecksum(*p.Checksum); err != nil {
+			v.report(table, i, "checksum", "%s", err)
+		}
+	}
+}
+
+func (v *validator) source(name string, s SourceConfig) {
+	table := "sources." + formatKey(name)
+	source := PluginSource(name)
+	if !contains(SupportedSources, source) {
+		message := fmt.Sprintf("unknown source [%s] (supported: %s)", table, joinValues(SupportedSources))
+		if suggestion := suggest(name, stringValues(SupportedSources)); suggestion != "" {
+			message = fmt.Sprintf("unknown source [%s], did you mean %q?", table, suggestion)
+		}
+		v.report(table, 0, "", "%s", message)
+		return
+	}
+
+	v.endpoint(table, s.BaseURL, s.Mirrors)
+	if len(s.Mirrors) > 0 && source == PluginSourceCustom {
+		v.report(table, 0, "mirrors", "mirrors are only used for APIs, custom plugins are downloaded from their download_url")
+	}
+	if s.APIKey != "" && source != PluginSourcePaperHangar {
+		v.report(table, 0, "api_key", "api_key is only used by paper-hangar, use token for %s", name)
+	}
+	if s.Token != "" && source == PluginSourcePaperHangar {
+		v.report(table, 0, "token", "paper-hangar authenticates with api_key, not token")
+	}
+	if (s.Username != "" || s.Password != "") && source != PluginSourceCustom {
+		key := "username"
+		if s.Username == "" {
+			key = "password"
+		}
+		v.report(table, 0, key, "basic auth is only used by custom plugins")
+	}
+	if s.Token != "" && s.Username != "" {
+		v.report(table, 0, "token", "set either token or username and password, not both")
+	}
+	if s.CacheTTL != "" && source == PluginSourceCustom {
+		v.report(table, 0, "cache_ttl", "cache_ttl is only used for APIs, custom plugins aren't looked up")
+	} else {
+		v.ttl(table, "cache_ttl", s.CacheTTL)
+	}
+}
+
+func (v *validator) vendorTable(name string, c VendorConfig) {
+	table := "vendors." + formatKey(name)
+	if !contains(SupportedVendors, ServerJarVendor(name)) {
+		message := fmt.Sprintf("unknown vendor [%s] (supported: %s)", table, joinValues(SupportedVendors))
+		if suggestion := suggest(name, stringValues(SupportedVendors)); suggestion != "" {
+			message = fmt.Sprintf("unknown vendor [%s], did you mean %q?", table, suggestion)
+		}
+		v.report(table, 0, "", "%s", message)
+		return
+	}
+	v.endpoint(table, c.BaseURL, c.Mirrors)
+	v.ttl(table, "cache_ttl", c.CacheTTL)
+}
+
+// endpoint checks the base_url and mirrors of a [sources] or [vendors] table.
+func (v *validator) endpoint(table, baseURL string, mirrors []string) {
+	if baseURL != "" && !isURL(baseURL) {
+		v.report(table, 0, "base_url", "base_url must be an http(s) URL, got %q", baseURL)
+	}
+	for _, mirror := range mirrors {
+		if !isURL(mirror) {
+			v.report(table, 0, "mirrors", "mirrors must be http(s) URLs, got %q", mirror)
+		}
+	}
+}
+
+func (v *validator) network(dir string, n NetworkConfig) {
+	if n.Proxy != "" {
+		u, err := url.Parse(n.Proxy)
+		if err != nil || u.Host == "" || !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) {
+			v.report("network", 0, "proxy", "proxy must be an http, https or socks5 URL, e.g. \"socks5://proxy:1080\"")
+		}
+	}
+	if n.CABundle != "" {
+		path := n.CABundle
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			v.report("network", 0, "ca_bundle", "ca_bundle %s not found", n.CABundle)
+		}
+	}
+}
+
+func (v *validator) cache(c CacheConfig) {
+	v.ttl("cache", "ttl", c.TTL)
+	for _, name := range slices.Sorted(maps.Keys(c.Namespaces)) {
+		if !slices.Contains(CacheNamespaces, name) {
+			message := fmt.Sprintf("unknown cache namespace %q (supported: %s)", name, strings.Join(CacheNamespaces, ", "))
+			if suggestion := suggest(name, CacheNamespaces); suggestion != "" {
+				message = fmt.Sprintf("unknown cache namespace %q, did you mean %q?", name, suggestion)
+			}
+			v.report("cache.namespaces", 0, name, "%s", message)
+			continue
+		}
+		v.ttl("cache.namespaces", name, c.Namespaces[name])
+	}
+}
+
+// ttl checks a cache TTL, which may be unset.
+func (v *validator) ttl(table, key, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := ParseTTL(value); err != nil {
+		v.report(table, 0, key, "%s", err)
+	}
+}
+
+// locate returns the line and column of key in a table, falling back to the
+// table header, or the start of the file, when the key isn't there.
+func (d *Document) locate(table string, index int, key string) (int, int) {
+	start, end, ok := d.section(table, index)
+	if !ok {
+		return 1, 1
+	}
+
+	if key != "" {
+		continuation := d.continuations()
+		for i := start; i < end; i++ {
+			if continuation[i] {
+				continue
+			}
+			if k, _, ok := parseKey(d.lines[i]); ok && k == key {
+				return i + 1, len(leadingSpace(d.lines[i])) + 1
+			}
+		}
+		// The key may be a table of its own.
+		for _, h := range d.headers() {
+			if h.name == strings.TrimPrefix(table+"."+key, ".") {
+				return h.line + 1, len(leadingSpace(d.lines[h.line])) + 1
+			}
+		}
+	}
+
+	if table == "" || start >= len(d.lines) {
+		return 1, 1
+	}
+	return start + 1, len(leadingSpace(d.lines[start])) + 1
+}
+
+// indexesWithKey returns the entries of an array of tables that set key, or
+// just 0 for plain tables.
+func (d *Document) indexesWithKey(table string, key string) []int {
+	var indexes []int
+	for i := 0; ; i++ {
+		start, end, ok := d.section(table, i)
+		if !ok {
+			break
+		}
+		if !d.isArray(table) {
+			return []int{0}
+		}
+		continuation := d.continuations()
+		for l := start; l < end; l++ {
+			if k, _, ok := parseKey(d.lines[l]); ok && !continuation[l] && k == key {
+				indexes = append(indexes, i)
+				break
+			}
+		}
+	}
+	if len(indexes) == 0 {
+		return []int{0}
+	}
+	return indexes
+}
+
+func (d *Document) isArray(table string) bool {
+	for _, h := range d.headers() {
+		if h.name == table {
+			return h.array
+		}
+	}
+	return false
+}
+
+// knownKeys maps table names to the keys they accept, derived from the toml
+// tags of the config types.
+var knownKeys = map[string][]string{
+	"":        tomlKeys(PlugstepConfig{}),
+	"server":  tomlKeys(ServerConfig{}),
+	"plugins": tomlKeys(PluginConfig{}),
+	"backups": tomlKeys(BackupConfig{}),
+	"lint":    tomlKeys(LintConfig{}),
+	"profile": tomlKeys(ProfileConfig{}),
+	"source":  tomlKeys(SourceConfig{}),
+	"vendor":  tomlKeys(VendorConfig{}),
+	"network": tomlKeys(NetworkConfig{}),
+	"cache":   tomlKeys(CacheConfig{}),
+}
+
+// tableKind maps a table path to its entry in knownKeys. The tables of a
+// profile accept the same keys as their base counterparts.
+func tableKind(path []string) string {
+	if len(path) == 2 && path[0] == "sources" {
+		return "source"
+	}
+	if len(path) == 2 && path[0] == "vendors" {
+		return "vendor"
+	}
+	if len(path) >= 2 && path[0] == "profiles" {
+		if len(path) == 2 {
+			return "profile"
+		}
+		return strings.Join(path[2:], ".")
+	}
+	return strings.Join(path, ".")
+}
+
+func tomlKeys(v interface{}) []string {
+	t := reflect.TypeOf(v)
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// suggest returns the candidate closest to s if it is likely a typo of it.
+func suggest(s string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(s), strings.ToLower(c))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(s)/3) {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+func stringValues[T ~string](values []T) []string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = string(v)
+	}
+	return strs
+}
+
+func joinValues[T ~string](values []T) string {
+	return strings.Join(stringValues(values), ", ")
+}
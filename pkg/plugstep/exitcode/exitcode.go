@@ -0,0 +1,73 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Code is the process exit status Plugstep reports for a failed command.
+type Code int
+
+const (
+	OK Code = 0
+	// Failure is any error that doesn't fit a more specific code.
+	Failure Code = 1
+	// Usage means the command line was invalid.
+	Usage Code = 2
+	// Config means plugstep.toml is missing, unreadable or invalid.
+	Config Code = 3
+	// Network means a remote API or download couldn't be reached.
+	Network Code = 4
+	// Checksum means a downloaded file didn't match its expected checksum.
+	Checksum Code = 5
+	// Partial means some plugins or the server jar installed and others failed.
+	Partial Code = 6
+)
+
+// Error tags an error with the exit code it should produce.
+type Error struct {
+	Code Code
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap tags err with code. A nil err stays nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Errorf formats an error tagged with code.
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Of returns the exit code for err: the code of the first Error in its chain,
+// Network for network errors that weren't tagged, and Failure otherwise.
+func Of(err error) Code {
+	if err == nil {
+		return OK
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Network
+	}
+
+	return Failure
+}
@@ -0,0 +1,55 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestOf_Nil(t *testing.T) {
+	if code := Of(nil); code != OK {
+		t.Errorf("expected OK, got %d", code)
+	}
+}
+
+func TestOf_Untagged(t *testing.T) {
+	if code := Of(errors.New("boom")); code != Failure {
+		t.Errorf("expected Failure, got %d", code)
+	}
+}
+
+func TestOf_WrappedCode(t *testing.T) {
+	err := fmt.Errorf("failed to load config: %w", Wrap(Config, errors.New("bad toml")))
+	if code := Of(err); code != Config {
+		t.Errorf("expected Config, got %d", code)
+	}
+	if err.Error() != "failed to load config: bad toml" {
+		t.Errorf("unexpected message: %s", err)
+	}
+}
+
+func TestOf_NetworkError(t *testing.T) {
+	err := fmt.Errorf("failed to fetch: %w", &url.Error{
+		Op:  "Get",
+		URL: "https://api.modrinth.com",
+		Err: &net.DNSError{Err: "no such host", Name: "api.modrinth.com"},
+	})
+	if code := Of(err); code != Network {
+		t.Errorf("expected Network, got %d", code)
+	}
+}
+
+func TestOf_JoinUsesFirstCode(t *testing.T) {
+	err := errors.Join(Errorf(Checksum, "mismatch"), Errorf(Network, "offline"))
+	if code := Of(err); code != Checksum {
+		t.Errorf("expected Checksum, got %d", code)
+	}
+}
+
+func TestWrap_NilStaysNil(t *testing.T) {
+	if err := Wrap(Config, nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
@@ -2,19 +2,58 @@ package plugins
 
 import (
 	"fmt"
+	"net/url"
 
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
 )
 
-type CustomPluginSource struct{}
+// noChecksum marks downloads without a published or configured checksum.
+// They are always re-downloaded and never verified.
+const noChecksum = "nocheck"
+
+type CustomPluginSource struct {
+	// baseURL is what relative download URLs are resolved against.
+	baseURL string
+}
 
 func (source *CustomPluginSource) GetPluginDownload(c config.PluginConfig) (*PluginDownload, error) {
 	if c.DownloadURL == nil {
 		return nil, fmt.Errorf("download URL is required for custom plugin source")
 	}
-	return &PluginDownload{
-		URL:          *c.DownloadURL,
-		Checksum:     "nocheck",
+	downloadURL, err := source.resolve(*c.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	download := &PluginDownload{
+		URL:          downloadURL,
+		Checksum:     noChecksum,
 		ChecksumType: ChecksumTypeSha256,
-	}, nil
+	}
+	if c.Checksum != nil && *c.Checksum != "" {
+		checksumType, digest, err := config.ParseChecksum(*c.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		download.Checksum = digest
+		download.ChecksumType = ChecksumType(checksumType)
+	}
+	return download, nil
+}
+
+func (source *CustomPluginSource) resolve(downloadURL string) (string, error) {
+	if source.baseURL == "" {
+		return downloadURL, nil
+	}
+	ref, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid download URL: %w", err)
+	}
+	if ref.IsAbs() {
+		return downloadURL, nil
+	}
+	base, err := url.Parse(source.baseURL + "/")
+	if err != nil {
+		return "", fmt.Errorf("invalid base_url: %w", err)
+	}
+	return base.ResolveReference(ref).String(), nil
 }
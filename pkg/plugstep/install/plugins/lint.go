@@ -0,0 +1,102 @@
+package plugins
+
+import (
+	"fmt"
+	"slices"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+)
+
+// inactiveStatuses are project statuses that mean a plugin won't get updates.
+var inactiveStatuses = []string{"archived", "withheld", "rejected"}
+
+func init() {
+	config.RegisterLintRule(config.LintRule{
+		ID:          "mc-incompatible",
+		Severity:    config.SeverityError,
+		Description: "a plugin version doesn't support the server's Minecraft version",
+		Check:       lintMinecraftCompatibility,
+	})
+	config.RegisterLintRule(config.LintRule{
+		ID:          "archived-project",
+		Severity:    config.SeverityWarn,
+		Description: "a plugin's project is archived or no longer available",
+		Check:       lintProjectStatus,
+	})
+}
+
+func lintMinecraftCompatibility(c *config.PlugstepConfig) []config.LintIssue {
+	var issues []config.LintIssue
+	for _, p := range c.Plugins {
+		source := GetSource(p.Source)
+		checker, ok := source.(CompatibilityChecker)
+		if !ok || p.Resource == nil {
+			continue
+		}
+
+		versions, err := checker.CompatibleVersions(p, c.Server.MinecraftVersion)
+		if err != nil {
+			issues = append(issues, uncheckedIssue(p, err))
+			continue
+		}
+		if len(versions) == 0 {
+			issues = append(issues, config.LintIssue{
+				Message: fmt.Sprintf("%s has no release for Minecraft %s", *p.Resource, c.Server.MinecraftVersion),
+				Plugin:  *p.Resource,
+			})
+			continue
+		}
+
+		current := ""
+		if p.Version != nil && *p.Version != "" {
+			current = *p.Version
+		} else {
+			download, err := source.GetPluginDownload(p)
+			if err != nil {
+				issues = append(issues, uncheckedIssue(p, err))
+				continue
+			}
+			current = download.Version
+		}
+
+		if !slices.Contains(versions, current) {
+			issues = append(issues, config.LintIssue{
+				Message: fmt.Sprintf("%s %s doesn't support Minecraft %s, %s does", *p.Resource, current, c.Server.MinecraftVersion, versions[0]),
+				Plugin:  *p.Resource,
+			})
+		}
+	}
+	return issues
+}
+
+func lintProjectStatus(c *config.PlugstepConfig) []config.LintIssue {
+	var issues []config.LintIssue
+	for _, p := range c.Plugins {
+		checker, ok := GetSource(p.Source).(StatusChecker)
+		if !ok || p.Resource == nil {
+			continue
+		}
+
+		status, err := checker.ProjectStatus(p)
+		if err != nil {
+			issues = append(issues, uncheckedIssue(p, err))
+			continue
+		}
+		if slices.Contains(inactiveStatuses, status) {
+			issues = append(issues, config.LintIssue{
+				Message: fmt.Sprintf("%s is %s on %s and won't receive updates", *p.Resource, status, p.Source),
+				Plugin:  *p.Resource,
+			})
+		}
+	}
+	return issues
+}
+
+// uncheckedIssue reports a plugin a rule couldn't check, e.g. when offline.
+func uncheckedIssue(p config.PluginConfig, err error) config.LintIssue {
+	return config.LintIssue{
+		Severity: config.SeverityInfo,
+		Message:  fmt.Sprintf("couldn't check %s: %v", *p.Resource, err),
+		Plugin:   *p.Resource,
+	}
+}
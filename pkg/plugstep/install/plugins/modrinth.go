@@ -3,17 +3,22 @@ package plugins
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
+	"time"
 
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 )
 
 type ModrinthPluginSource struct {
-	apiURL string
+	api utils.Endpoint
+	ttl time.Duration
 }
 
 type ModrinthVersion struct {
 	VersionNumber string         `json:"version_number"`
+	GameVersions  []string       `json:"game_versions"`
 	Files         []ModrinthFile `json:"files"`
 }
 
@@ -38,31 +43,9 @@ func (m *ModrinthPluginSource) GetPluginDownload(c config.PluginConfig) (*Plugin
 		}
 	}
 
-	// Fetch version list (short TTL cache for latest discovery)
-	versionsCacheKey := fmt.Sprintf("modrinth:%s:versions", *c.Resource)
-	var response []ModrinthVersion
-	if cache != nil && cache.Get(versionsCacheKey, &response) {
-		// Cache hit
-	} else {
-		url := fmt.Sprintf("%s/project/%s/version", m.apiURL, *c.Resource)
-		r, err := utils.HTTPClient.Get(url)
-		if err != nil {
-			return nil, err
-		}
-		defer r.Body.Close()
-
-		if r.StatusCode != 200 {
-			return nil, fmt.Errorf("got %d", r.StatusCode)
-		}
-
-		err = json.NewDecoder(r.Body).Decode(&response)
-		if err != nil {
-			return nil, err
-		}
-
-		if cache != nil {
-			cache.Set(versionsCacheKey, response) // Short TTL
-		}
+	response, err := m.getVersions(*c.Resource)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(response) == 0 {
@@ -100,6 +83,61 @@ func (m *ModrinthPluginSource) GetPluginDownload(c config.PluginConfig) (*Plugin
 	return download, nil
 }
 
+// CompatibleVersions returns the version numbers that list minecraftVersion
+// as a supported game version, newest first.
+func (m *ModrinthPluginSource) CompatibleVersions(c config.PluginConfig, minecraftVersion string) ([]string, error) {
+	response, err := m.getVersions(*c.Resource)
+	if err != nil {
+		return nil, err
+	}
+	return filterModrinthVersions(response, minecraftVersion), nil
+}
+
+// ProjectStatus returns the moderation status of the project.
+func (m *ModrinthPluginSource) ProjectStatus(c config.PluginConfig) (string, error) {
+	projectCacheKey := fmt.Sprintf("modrinth:%s:project", *c.Resource)
+
+	var project struct {
+		Status string `json:"status"`
+	}
+	err := utils.CachedGet(GetCache(), projectCacheKey, m.ttl, m.api, fmt.Sprintf("/project/%s", *c.Resource), &project, decodeJSON)
+	if err != nil {
+		return "", err
+	}
+	return project.Status, nil
+}
+
+// getVersions fetches the version list of a project, revalidated after the
+// source's TTL so that new latest versions are discovered
+func (m *ModrinthPluginSource) getVersions(resource string) ([]ModrinthVersion, error) {
+	versionsCacheKey := fmt.Sprintf("modrinth:%s:versions", resource)
+
+	var response []ModrinthVersion
+	err := utils.CachedGet(GetCache(), versionsCacheKey, m.ttl, m.api, fmt.Sprintf("/project/%s/version", resource), &response, decodeJSON)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// decodeJSON decodes a successful JSON API response into dest.
+func decodeJSON(r *http.Response, dest interface{}) error {
+	if r.StatusCode != 200 {
+		return fmt.Errorf("got %d from %s", r.StatusCode, r.Request.URL)
+	}
+	return json.NewDecoder(r.Body).Decode(dest)
+}
+
+func filterModrinthVersions(response []ModrinthVersion, minecraftVersion string) []string {
+	var versions []string
+	for _, v := range response {
+		if slices.Contains(v.GameVersions, minecraftVersion) {
+			versions = append(versions, v.VersionNumber)
+		}
+	}
+	return versions
+}
+
 func findModrinthVersion(response []ModrinthVersion, version string) *ModrinthVersion {
 	for _, resp := range response {
 		if resp.VersionNumber == version {
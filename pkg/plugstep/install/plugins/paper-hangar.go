@@ -4,14 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 )
 
 type PaperHangarPluginSource struct {
-	apiURL string
+	api utils.Endpoint
+	ttl time.Duration
 }
 
 type PaperHangarVersion struct {
@@ -47,15 +52,14 @@ func (m *PaperHangarPluginSource) GetPluginDownload(c config.PluginConfig) (*Plu
 		return &cached, nil
 	}
 
-	url := fmt.Sprintf("%s/projects/%s/versions/%s", m.apiURL, *c.Resource, version)
-	r, err := utils.HTTPClient.Get(url)
+	r, err := m.api.Get(fmt.Sprintf("/projects/%s/versions/%s", *c.Resource, version))
 	if err != nil {
 		return nil, err
 	}
 	defer r.Body.Close()
 
 	if r.StatusCode != 200 {
-		return nil, fmt.Errorf("got %d from %s", r.StatusCode, url)
+		return nil, fmt.Errorf("got %d from %s", r.StatusCode, r.Request.URL)
 	}
 
 	var response PaperHangarVersion
@@ -88,31 +92,110 @@ func (m *PaperHangarPluginSource) getLatestVersion(resource string) (string, err
 	cacheKey := fmt.Sprintf("hangar:%s:latest", resource)
 
 	var version string
-	if cache := GetCache(); cache != nil && cache.Get(cacheKey, &version) {
-		return version, nil
-	}
-
-	url := fmt.Sprintf("%s/projects/%s/latestrelease", m.apiURL, resource)
-	r, err := utils.HTTPClient.Get(url)
+	err := utils.CachedGet(GetCache(), cacheKey, m.ttl, m.api, fmt.Sprintf("/projects/%s/latestrelease", resource), &version, decodeHangarText)
 	if err != nil {
 		return "", err
 	}
-	defer r.Body.Close()
+	return version, nil
+}
 
+// decodeHangarText decodes a plain text response, e.g. the latest release.
+func decodeHangarText(r *http.Response, dest interface{}) error {
 	if r.StatusCode != 200 {
-		return "", fmt.Errorf("got %d from %s", r.StatusCode, url)
+		return fmt.Errorf("got %d from %s", r.StatusCode, r.Request.URL)
 	}
 
 	// Response is plain text, not JSON
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		return "", err
+		return err
 	}
-	version = strings.TrimSpace(string(body))
+	*dest.(*string) = strings.TrimSpace(string(body))
+	return nil
+}
 
-	if cache := GetCache(); cache != nil {
-		cache.Set(cacheKey, version)
+// hangarPageSize is the largest page of versions Hangar returns.
+const hangarPageSize = 25
+
+// CompatibleVersions returns the versions whose Paper platform dependencies
+// include minecraftVersion, newest first.
+func (m *PaperHangarPluginSource) CompatibleVersions(c config.PluginConfig, minecraftVersion string) ([]string, error) {
+	var versions []string
+	for offset := 0; ; offset += hangarPageSize {
+		cacheKey := fmt.Sprintf("hangar:%s:compatible:%s:%d", *c.Resource, minecraftVersion, offset)
+
+		var response struct {
+			Pagination struct {
+				Count int `json:"count"`
+			} `json:"pagination"`
+			Result []struct {
+				Name string `json:"name"`
+			} `json:"result"`
+		}
+		path := fmt.Sprintf("/projects/%s/versions?limit=%d&offset=%d&platform=PAPER&platformVersion=%s", *c.Resource, hangarPageSize, offset, url.QueryEscape(minecraftVersion))
+		if err := utils.CachedGet(GetCache(), cacheKey, m.ttl, m.api, path, &response, decodeJSON); err != nil {
+			return nil, err
+		}
+
+		for _, v := range response.Result {
+			versions = append(versions, v.Name)
+		}
+		if len(response.Result) == 0 || offset+len(response.Result) >= response.Pagination.Count {
+			return versions, nil
+		}
 	}
+}
 
-	return version, nil
+// hangarSession is a Hangar JWT obtained for an API key.
+type hangarSession struct {
+	apiURL string
+	apiKey string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// hangarAuthorizer authenticates requests to Hangar with a session token,
+// exchanging the API key for a new one when it expires.
+func hangarAuthorizer(apiURL, apiKey string) func(req *http.Request) error {
+	session := &hangarSession{apiURL: apiURL, apiKey: apiKey}
+	return session.authorize
+}
+
+func (s *hangarSession) authorize(req *http.Request) error {
+	if strings.HasSuffix(req.URL.Path, "/authenticate") {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == "" || time.Now().After(s.expires) {
+		r, err := utils.HTTPClient.Post(fmt.Sprintf("%s/authenticate?apiKey=%s", s.apiURL, url.QueryEscape(s.apiKey)), "application/json", nil)
+		if err != nil {
+			return fmt.Errorf("failed to authenticate with Hangar: %w", err)
+		}
+		defer r.Body.Close()
+
+		if r.StatusCode != 200 {
+			return fmt.Errorf("failed to authenticate with Hangar: got %d, check api_key in [sources.paper-hangar]", r.StatusCode)
+		}
+
+		var response struct {
+			Token     string `json:"token"`
+			ExpiresIn int64  `json:"expiresIn"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			return fmt.Errorf("failed to authenticate with Hangar: %w", err)
+		}
+		utils.RegisterSecret(response.Token)
+
+		s.token = response.Token
+		// expiresIn is in milliseconds; renew a minute early.
+		s.expires = time.Now().Add(time.Duration(response.ExpiresIn)*time.Millisecond - time.Minute)
+	}
+
+	req.Header.Set("Authorization", "HangarAuth "+s.token)
+	return nil
 }
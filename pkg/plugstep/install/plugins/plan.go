@@ -0,0 +1,115 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/manifest"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
+)
+
+// PluginPlan describes what an install would do for a single plugin.
+type PluginPlan struct {
+	Plugin   *config.PluginConfig
+	Download *PluginDownload
+	File     string
+	UpToDate bool
+	Err      error
+}
+
+// Report describes the plan as an output.PluginReport, with a status of
+// up-to-date, outdated, missing or failed.
+func (p PluginPlan) Report(serverDirectory string) output.PluginReport {
+	report := output.PluginReport{
+		Source:       string(p.Plugin.Source),
+		File:         p.File,
+		Profile:      p.Plugin.Profile,
+		IncludedFrom: p.Plugin.IncludedFrom,
+	}
+	if p.Plugin.Resource != nil {
+		report.Resource = *p.Plugin.Resource
+	}
+	if p.Plugin.Version != nil && *p.Plugin.Version != "" {
+		report.PinnedVersion = p.Plugin.Version
+	}
+	if rel, err := filepath.Rel(serverDirectory, p.File); err == nil {
+		report.File = filepath.ToSlash(rel)
+	}
+
+	if p.Download != nil {
+		report.ResolvedVersion = p.Download.Version
+	}
+	if p.HasChecksum() {
+		report.Checksum = p.Download.Checksum
+		report.ChecksumType = string(p.Download.ChecksumType)
+	}
+
+	switch {
+	case p.Err != nil:
+		report.Status = output.StatusFailed
+		report.Error = p.Err.Error()
+	case p.UpToDate:
+		report.Status = output.StatusUpToDate
+	default:
+		report.Status = output.StatusMissing
+		if _, err := os.Stat(p.File); err == nil {
+			report.Status = output.StatusOutdated
+		}
+	}
+
+	return report
+}
+
+// Plan describes what InstallPlugins would do, without touching disk.
+type Plan struct {
+	Plugins  []PluginPlan
+	Removals []string
+}
+
+// PlanPlugins resolves every configured plugin and lists the files that would
+// be removed from plugins/, without downloading or deleting anything.
+func PlanPlugins(ps *plugstep.Plugstep) (*Plan, error) {
+	InitCache()
+
+	plan := &Plan{
+		Plugins: make([]PluginPlan, len(ps.Config.Plugins)),
+	}
+
+	sem := make(chan struct{}, maxConcurrentDownloads)
+	var wg sync.WaitGroup
+	for i := range ps.Config.Plugins {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			plan.Plugins[i] = planPlugin(ps, &ps.Config.Plugins[i])
+		}(i)
+	}
+	wg.Wait()
+
+	m, err := manifest.Load(ps.ServerDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := removableFiles(ps, m)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range files {
+		plan.Removals = append(plan.Removals, filepath.Join(ps.ServerDirectory, "plugins", f))
+	}
+
+	return plan, nil
+}
+
+// HasChecksum reports whether the plugin's download is verified, and so can
+// be kept in the download store.
+func (p PluginPlan) HasChecksum() bool {
+	return p.Download != nil && p.Download.Checksum != noChecksum
+}
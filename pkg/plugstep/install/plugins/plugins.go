@@ -3,32 +3,24 @@ package plugins
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/bundle"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/manifest"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 )
 
 const maxConcurrentDownloads = 25
 
-type installResult struct {
-	plugin *config.PluginConfig
-	status PluginInstallStatus
-	err    error
-}
-
-type progressUpdate struct {
-	name       string
-	downloaded int64
-	total      int64
-}
-
 type PluginInstallStatus string
 
 const (
@@ -40,198 +32,12 @@ const (
 	PluginInstallStatusDownloading PluginInstallStatus = "downloading"
 )
 
-type model struct {
-	plugins    []pluginState
-	results    <-chan installResult
-	progressCh <-chan progressUpdate
-	installed  int
-	checked    int
-	errors     []string
-	total      int
-	done       bool
-}
-
-type pluginState struct {
-	name       string
-	source     config.PluginSource
-	status     PluginInstallStatus
-	downloaded int64
-	total      int64
-}
-
-type pluginResultMsg installResult
-type progressMsg progressUpdate
-type allDoneMsg struct{}
-
-func (m model) Init() tea.Cmd {
-	return tea.Batch(
-		waitForResult(m.results),
-		waitForProgress(m.progressCh),
-	)
-}
-
-func waitForResult(results <-chan installResult) tea.Cmd {
-	return func() tea.Msg {
-		result, ok := <-results
-		if !ok {
-			return allDoneMsg{}
-		}
-		return pluginResultMsg(result)
-	}
-}
-
-func waitForProgress(progressCh <-chan progressUpdate) tea.Cmd {
-	return func() tea.Msg {
-		update, ok := <-progressCh
-		if !ok {
-			return nil
-		}
-		return progressMsg(update)
-	}
-}
-
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case pluginResultMsg:
-		for i := range m.plugins {
-			if m.plugins[i].name == *msg.plugin.Resource {
-				if msg.err != nil {
-					m.plugins[i].status = PluginInstallFailed
-					m.errors = append(m.errors, fmt.Sprintf("%s: %v", *msg.plugin.Resource, msg.err))
-				} else {
-					m.plugins[i].status = msg.status
-					if msg.status == PluginInstallStatusInstalled {
-						m.installed++
-					} else if msg.status == PluginInstallStatusChecked {
-						m.checked++
-					}
-				}
-				break
-			}
-		}
-		return m, tea.Batch(waitForResult(m.results), waitForProgress(m.progressCh))
-
-	case progressMsg:
-		for i := range m.plugins {
-			if m.plugins[i].name == msg.name {
-				if m.plugins[i].status != PluginInstallStatusInstalled &&
-					m.plugins[i].status != PluginInstallStatusChecked &&
-					m.plugins[i].status != PluginInstallFailed {
-					m.plugins[i].downloaded = msg.downloaded
-					m.plugins[i].total = msg.total
-					m.plugins[i].status = PluginInstallStatusDownloading
-				}
-				break
-			}
-		}
-		return m, tea.Batch(waitForResult(m.results), waitForProgress(m.progressCh))
-
-	case allDoneMsg:
-		m.done = true
-		return m, tea.Quit
-
-	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" {
-			return m, tea.Quit
-		}
-	}
-	return m, nil
-}
-
-func (m model) View() string {
-	var b strings.Builder
-
-	for _, p := range m.plugins {
-		b.WriteString(renderPluginLine(p))
-		b.WriteString("\n")
-	}
-
-	return b.String()
-}
-
-func renderPluginLine(p pluginState) string {
-	badge := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#cdd6f4")).
-		PaddingRight(1).
-		Bold(true).
-		PaddingLeft(1).
-		Render("~")
-
-	sourceBadge := lipgloss.NewStyle().
-		Background(lipgloss.Color("#89b4fa")).
-		Foreground(lipgloss.Color("#11111b")).
-		Width(16).
-		PaddingLeft(1).
-		Transform(strings.ToUpper).
-		Render(string(p.source))
-
-	background := "#f9e2af"
-	switch p.status {
-	case PluginInstallFailed:
-		background = "#f38ba8"
-	case PluginInstallStatusChecked:
-		background = "#89dceb"
-	case PluginInstallStatusInstalled:
-		background = "#a6e3a1"
-	case PluginInstallStatusDownloading:
-		background = "#89b4fa"
-	}
-
-	statusBadge := lipgloss.NewStyle().
-		Background(lipgloss.Color(background)).
-		Foreground(lipgloss.Color("#232634")).
-		Width(15).
-		PaddingLeft(1).
-		Transform(strings.ToUpper).
-		Render(string(p.status))
-
-	nameBadge := lipgloss.NewStyle().
-		Width(30).
-		Render(p.name)
-
-	line := fmt.Sprintf("%s%s%s %s", badge, sourceBadge, statusBadge, nameBadge)
-
-	if p.status == PluginInstallStatusDownloading {
-		line += " " + renderProgressBar(p.downloaded, p.total, 20)
-	}
-
-	return line
-}
-
-func renderProgressBar(downloaded, total int64, width int) string {
-	var percent float64
-	if total > 0 {
-		percent = float64(downloaded) / float64(total)
-	}
-
-	filled := int(percent * float64(width))
-	if filled > width {
-		filled = width
-	}
-
-	filledStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#a6e3a1"))
-	emptyStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#45475a"))
-
-	bar := filledStyle.Render(strings.Repeat("—", filled)) +
-		emptyStyle.Render(strings.Repeat("—", width-filled))
-
-	return fmt.Sprintf("[%s] %s/%s", bar, formatSize(downloaded), formatSize(total))
-}
-
-func formatSize(bytes int64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-	)
-	switch {
-	case bytes >= MB:
-		return fmt.Sprintf("%.1f MB", float64(bytes)/float64(MB))
-	case bytes >= KB:
-		return fmt.Sprintf("%.0f KB", float64(bytes)/float64(KB))
-	default:
-		return fmt.Sprintf("%d B", bytes)
+func pluginEvent(p *config.PluginConfig, status PluginInstallStatus) output.Event {
+	return output.Event{
+		Kind:   output.KindPlugin,
+		Name:   *p.Resource,
+		Source: string(p.Source),
+		Status: output.Status(status),
 	}
 }
 
@@ -241,19 +47,24 @@ func InstallPlugins(ps *plugstep.Plugstep) error {
 	InitCache()
 	utils.EnsureDirectory(filepath.Join(ps.ServerDirectory, "plugins"))
 
-	plugins := make([]pluginState, len(ps.Config.Plugins))
-	for i, p := range ps.Config.Plugins {
-		plugins[i] = pluginState{
-			name:   *p.Resource,
-			source: p.Source,
-			status: PluginInstallWaiting,
-		}
+	mf, err := manifest.Load(ps.ServerDirectory)
+	if err != nil {
+		return fmt.Errorf("failed to load manifest: %w", err)
 	}
 
-	sem := make(chan struct{}, maxConcurrentDownloads)
-	results := make(chan installResult, len(ps.Config.Plugins))
-	progressCh := make(chan progressUpdate, 100)
+	stream := output.StartFor(ps.Options.Output, ps.Options.Member)
+	for i := range ps.Config.Plugins {
+		stream.Send(pluginEvent(&ps.Config.Plugins[i], PluginInstallWaiting))
+	}
 
+	var (
+		mu        sync.Mutex
+		installed int
+		checked   int
+		errors    []error
+	)
+
+	sem := make(chan struct{}, maxConcurrentDownloads)
 	var wg sync.WaitGroup
 	for i := range ps.Config.Plugins {
 		plugin := &ps.Config.Plugins[i]
@@ -263,48 +74,94 @@ func InstallPlugins(ps *plugstep.Plugstep) error {
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			status, err := installPlugin(ps, p, progressCh)
-			results <- installResult{plugin: p, status: status, err: err}
+			stream.Send(pluginEvent(p, PluginInstallPreparing))
+			plan, status, err := installPlugin(ps, mf, p, stream)
+
+			report := plan.Report(ps.ServerDirectory)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				event := pluginEvent(p, PluginInstallFailed)
+				event.Error = err.Error()
+				report.Status = output.StatusFailed
+				report.Error = err.Error()
+				event.Plugin = &report
+				stream.Send(event)
+				errors = append(errors, fmt.Errorf("%s: %w", *p.Resource, err))
+				return
+			}
+			event := pluginEvent(p, status)
+			report.Status = output.Status(status)
+			event.Plugin = &report
+			stream.Send(event)
+			switch status {
+			case PluginInstallStatusInstalled:
+				installed++
+			case PluginInstallStatusChecked:
+				checked++
+			}
 		}(plugin)
 	}
 
-	go func() {
-		wg.Wait()
-		close(results)
-		close(progressCh)
-	}()
-
-	m := model{
-		plugins:    plugins,
-		results:    results,
-		progressCh: progressCh,
-		total:      len(ps.Config.Plugins),
-	}
-
-	finalModel, err := tea.NewProgram(m).Run()
-	if err != nil {
-		return fmt.Errorf("UI error: %w", err)
+	wg.Wait()
+	if err := stream.Close(); err != nil {
+		log.Error("Failed to render progress", "err", err)
 	}
 
-	fm := finalModel.(model)
+	defer func() {
+		mf.Prune()
+		if err := mf.Save(); err != nil {
+			log.Error("Failed to save manifest", "err", err)
+		}
+	}()
 
-	if len(fm.errors) > 0 {
-		for _, e := range fm.errors {
+	if len(errors) > 0 && utils.Offline && ps.Options.Bundle == nil {
+		return offlineError(errors, len(ps.Config.Plugins))
+	}
+	if len(errors) > 0 {
+		for _, e := range errors {
 			log.Error("Plugin installation failed", "error", e)
 		}
-		return fmt.Errorf("%d plugin(s) failed to install", len(fm.errors))
+		// A checksum mismatch may be a tampered download and outranks the
+		// rest. Nothing installed at all is reported with the cause of the
+		// first failure, anything less as a partial install.
+		for _, e := range errors {
+			if exitcode.Of(e) == exitcode.Checksum {
+				return exitcode.Errorf(exitcode.Checksum, "%d of %d plugin(s) failed to install: %w", len(errors), len(ps.Config.Plugins), e)
+			}
+		}
+		if len(errors) == len(ps.Config.Plugins) {
+			return fmt.Errorf("%d plugin(s) failed to install: %w", len(errors), errors[0])
+		}
+		return exitcode.Errorf(exitcode.Partial, "%d of %d plugin(s) failed to install", len(errors), len(ps.Config.Plugins))
 	}
 
-	removed := removeOld(ps)
+	removed := removeOld(ps, mf)
 
-	log.Info("Plugins ready.", "installed", fm.installed, "checked", fm.checked, "removed", removed)
+	log.Info("Plugins ready.", "installed", installed, "checked", checked, "removed", removed)
 
 	return nil
 }
 
+// offlineError lists every plugin an offline install couldn't find, so they
+// can all be fetched at once.
+func offlineError(errs []error, total int) error {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = "\n  " + err.Error()
+	}
+	slices.Sort(lines)
+
+	code := exitcode.Partial
+	if len(errs) == total {
+		code = exitcode.Network
+	}
+	return exitcode.Errorf(code, "%d of %d plugin(s) are missing offline, run `plugstep fetch` online first:%s", len(errs), total, strings.Join(lines, ""))
+}
+
 // TODO: Add error handling
-func removeOld(ps *plugstep.Plugstep) int {
-	entries, err := os.ReadDir(filepath.Join(ps.ServerDirectory, "plugins"))
+func removeOld(ps *plugstep.Plugstep, m *manifest.Manifest) int {
+	files, err := removableFiles(ps, m)
 	if err != nil {
 		log.Error("Error reading directory", "err", err)
 		return 0
@@ -312,6 +169,58 @@ func removeOld(ps *plugstep.Plugstep) int {
 
 	removed := 0
 
+	for _, name := range files {
+		if err := ps.Backup.Save(path.Join("plugins", name)); err != nil {
+			log.Warn("failed to back up old plugin, keeping it", "file", name, "err", err)
+			continue
+		}
+		err := os.Remove(filepath.Join(ps.ServerDirectory, "plugins", name))
+		if err != nil {
+			log.Warn("failed to remove old plugin", "file", name, "err", err)
+			continue
+		}
+		m.Forget(path.Join("plugins", name))
+		log.Infof("Removed %s", strings.Split(name, ".")[0])
+		removed++
+	}
+
+	return removed
+}
+
+// removableFiles lists the old files in plugins/ that cleanup may delete: those
+// Plugstep installed and nobody modified since, or every old file when
+// pruning unmanaged files.
+func removableFiles(ps *plugstep.Plugstep, m *manifest.Manifest) ([]string, error) {
+	files, err := oldPluginFiles(ps)
+	if err != nil {
+		return nil, err
+	}
+
+	var removable []string
+	for _, name := range files {
+		rel := path.Join("plugins", name)
+		switch {
+		case ps.Options.PruneUnmanaged || m.Owns(rel):
+			removable = append(removable, name)
+		case m.Managed(rel):
+			log.Warn("Keeping plugin file modified since install", "file", rel)
+		default:
+			log.Debug("Keeping unmanaged file", "file", rel)
+		}
+	}
+
+	return removable, nil
+}
+
+// oldPluginFiles lists the files in plugins/ that don't belong to any
+// configured plugin.
+func oldPluginFiles(ps *plugstep.Plugstep) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(ps.ServerDirectory, "plugins"))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
 	for _, f := range entries {
 		if f.IsDir() {
 			continue
@@ -320,64 +229,176 @@ func removeOld(ps *plugstep.Plugstep) int {
 		for _, p := range ps.Config.Plugins {
 			if f.Name() == *p.Resource+".jar" {
 				found = true
-				continue
+				break
 			}
 		}
-		if found == true {
-			continue
+		if !found {
+			files = append(files, f.Name())
 		}
+	}
 
-		err := os.Remove(filepath.Join(ps.ServerDirectory, "plugins", f.Name()))
-		if err != nil {
-			log.Warn("failed to remove old plugin", "file", f.Name(), "err", err)
-			continue
+	return files, nil
+}
+
+func installPlugin(ps *plugstep.Plugstep, m *manifest.Manifest, p *config.PluginConfig, stream *output.Stream) (PluginPlan, PluginInstallStatus, error) {
+	plan := planPlugin(ps, p)
+	if plan.Err != nil {
+		return plan, "", plan.Err
+	}
+
+	entry := manifest.Entry{Source: string(p.Source), Resource: *p.Resource}
+	rel := path.Join("plugins", *p.Resource+".jar")
+
+	if plan.UpToDate {
+		if err := m.Record(rel, entry); err != nil {
+			log.Debug("failed to record plugin in manifest", "file", rel, "err", err)
 		}
-		log.Infof("Removed %s", strings.Split(f.Name(), ".")[0])
-		removed++
+		return plan, PluginInstallStatusChecked, nil
 	}
 
-	return removed
+	// Without a checksum there is nothing to find in the store, so offline the
+	// jar already installed is used as it is.
+	if plan.Download.Checksum == noChecksum && utils.Offline {
+		if _, err := os.Stat(plan.File); err == nil {
+			return plan, PluginInstallStatusChecked, nil
+		}
+		return plan, PluginInstallFailed, fmt.Errorf("%s is %w, add a checksum to keep it in the download store", plan.Download.URL, utils.ErrOffline)
+	}
+
+	if err := ps.Backup.Save(rel); err != nil {
+		return plan, PluginInstallFailed, err
+	}
+
+	onProgress := func(downloaded, total int64) {
+		event := pluginEvent(p, PluginInstallStatusDownloading)
+		event.Downloaded = downloaded
+		event.Total = total
+		stream.Progress(event)
+	}
+
+	var err error
+	switch {
+	case ps.Options.Bundle != nil:
+		src := ps.Options.Bundle.Path(string(plan.Download.ChecksumType), plan.Download.Checksum)
+		err = utils.InstallVerified(src, plan.File, string(plan.Download.ChecksumType), plan.Download.Checksum)
+	case plan.Download.Checksum == noChecksum:
+		err = utils.DownloadFileWithProgress(plan.Download.URL, plan.File, onProgress)
+	default:
+		err = utils.DownloadVerified(plan.Download.URL, plan.File, string(plan.Download.ChecksumType), plan.Download.Checksum, onProgress)
+	}
+	if err != nil {
+		if exitcode.Of(err) == exitcode.Checksum {
+			return plan, PluginInstallFailed, err
+		}
+		return plan, PluginInstallFailed, fmt.Errorf("failed to download plugin: %w", err)
+	}
+
+	if err := m.Record(rel, entry); err != nil {
+		log.Debug("failed to record plugin in manifest", "file", rel, "err", err)
+	}
+
+	return plan, PluginInstallStatusInstalled, nil
 }
 
-func installPlugin(ps *plugstep.Plugstep, p *config.PluginConfig, progressCh chan<- progressUpdate) (PluginInstallStatus, error) {
+// planPlugin resolves the download for a plugin and checks whether the jar
+// already on disk matches it, without downloading anything.
+func planPlugin(ps *plugstep.Plugstep, p *config.PluginConfig) PluginPlan {
+	plan := PluginPlan{
+		Plugin: p,
+		File:   filepath.Join(ps.ServerDirectory, "plugins", *p.Resource+".jar"),
+	}
+
 	source := GetSource(p.Source)
 	if source == nil {
-		return "", fmt.Errorf("invalid source")
+		plan.Err = fmt.Errorf("invalid source")
+		return plan
 	}
 
-	download, err := source.GetPluginDownload(*p)
+	var download *PluginDownload
+	var err error
+	if ps.Options.Bundle != nil {
+		download, err = bundledDownload(ps.Options.Bundle, *p)
+	} else {
+		download, err = resolveDownload(source, *p)
+	}
 	if err != nil {
-		return "", err
+		plan.Err = err
+		return plan
 	}
-
-	file := filepath.Join(ps.ServerDirectory, "plugins", *p.Resource+".jar")
+	plan.Download = download
 
 	var hash string
 	var hashErr error
 	switch download.ChecksumType {
 	case ChecksumTypeSha256:
-		hash, hashErr = utils.CalculateFileSHA256(file)
+		hash, hashErr = utils.CalculateFileSHA256(plan.File)
 	case ChecksumTypeSha512:
-		hash, hashErr = utils.CalculateFileSHA512(file)
+		hash, hashErr = utils.CalculateFileSHA512(plan.File)
 	}
 
 	if hashErr != nil && !os.IsNotExist(hashErr) {
-		log.Debug("failed to calculate file hash", "file", file, "err", hashErr)
+		log.Debug("failed to calculate file hash", "file", plan.File, "err", hashErr)
 	}
 
-	if hash == download.Checksum {
-		return PluginInstallStatusChecked, nil
+	plan.UpToDate = hash == download.Checksum
+	return plan
+}
+
+// resolution is a download being resolved, shared by everyone asking for the
+// same plugin meanwhile.
+type resolution struct {
+	done     chan struct{}
+	download *PluginDownload
+	err      error
+}
+
+var (
+	resolutionsMu sync.Mutex
+	resolutions   = map[string]*resolution{}
+)
+
+// resolveDownload resolves the download of a plugin, joining a resolution of
+// the same plugin that is already in flight, e.g. from another workspace
+// member. Finished resolutions are reused through the sources' caches.
+func resolveDownload(source PluginSource, p config.PluginConfig) (*PluginDownload, error) {
+	key := strings.Join([]string{string(p.Source), strings.ToLower(*p.Resource), stringValue(p.Version), stringValue(p.DownloadURL), stringValue(p.Checksum)}, "\x00")
+
+	resolutionsMu.Lock()
+	if r, ok := resolutions[key]; ok {
+		resolutionsMu.Unlock()
+		<-r.done
+		return r.download, r.err
 	}
+	r := &resolution{done: make(chan struct{})}
+	resolutions[key] = r
+	resolutionsMu.Unlock()
 
-	err = utils.DownloadFileWithProgress(download.URL, file, func(downloaded, total int64) {
-		select {
-		case progressCh <- progressUpdate{name: *p.Resource, downloaded: downloaded, total: total}:
-		default:
-		}
-	})
+	r.download, r.err = source.GetPluginDownload(p)
+	close(r.done)
+
+	resolutionsMu.Lock()
+	delete(resolutions, key)
+	resolutionsMu.Unlock()
+	return r.download, r.err
+}
+
+// bundledDownload resolves a plugin from the bundle an install reads from.
+func bundledDownload(b *bundle.Bundle, p config.PluginConfig) (*PluginDownload, error) {
+	bundled, err := b.FindPlugin(p)
 	if err != nil {
-		return PluginInstallFailed, fmt.Errorf("failed to download plugin: %w", err)
+		return nil, err
 	}
+	return &PluginDownload{
+		URL:          bundled.URL,
+		Checksum:     bundled.Checksum,
+		ChecksumType: ChecksumType(bundled.ChecksumType),
+		Version:      bundled.Version,
+	}, nil
+}
 
-	return PluginInstallStatusInstalled, nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
@@ -1,9 +1,25 @@
 package plugins
 
 import (
+	"bytes"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/manifest"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 )
 
 // --- GetSource() Tests ---
@@ -20,8 +36,8 @@ func TestGetSource_ReturnsModrinthSource(t *testing.T) {
 		t.Fatalf("expected *ModrinthPluginSource, got %T", source)
 	}
 
-	if modrinth.apiURL != "https://api.modrinth.com/v2" {
-		t.Errorf("expected API URL %q, got %q", "https://api.modrinth.com/v2", modrinth.apiURL)
+	if modrinth.api.URL != "https://api.modrinth.com/v2" {
+		t.Errorf("expected API URL %q, got %q", "https://api.modrinth.com/v2", modrinth.api.URL)
 	}
 }
 
@@ -37,8 +53,8 @@ func TestGetSource_ReturnsPaperHangarSource(t *testing.T) {
 		t.Fatalf("expected *PaperHangarPluginSource, got %T", source)
 	}
 
-	if hangar.apiURL != "https://hangar.papermc.io/api/v1" {
-		t.Errorf("expected API URL %q, got %q", "https://hangar.papermc.io/api/v1", hangar.apiURL)
+	if hangar.api.URL != "https://hangar.papermc.io/api/v1" {
+		t.Errorf("expected API URL %q, got %q", "https://hangar.papermc.io/api/v1", hangar.api.URL)
 	}
 }
 
@@ -316,6 +332,273 @@ func TestCustomPluginSource_GetPluginDownload_EmptyStringURL(t *testing.T) {
 	}
 }
 
+func TestCustomPluginSource_GetPluginDownload_WithChecksum(t *testing.T) {
+	source := &CustomPluginSource{}
+	downloadURL := "https://example.com/plugin.jar"
+	checksum := "sha512:" + strings.Repeat("AB", 64)
+	pluginConfig := config.PluginConfig{
+		Source:      config.PluginSourceCustom,
+		DownloadURL: &downloadURL,
+		Checksum:    &checksum,
+	}
+
+	download, err := source.GetPluginDownload(pluginConfig)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if download.Checksum != strings.Repeat("ab", 64) || download.ChecksumType != ChecksumTypeSha512 {
+		t.Errorf("expected configured sha512 checksum, got %s %q", download.ChecksumType, download.Checksum)
+	}
+
+	invalid := "sha1:abc"
+	pluginConfig.Checksum = &invalid
+	if _, err := source.GetPluginDownload(pluginConfig); err == nil {
+		t.Error("expected error for invalid checksum")
+	}
+}
+
+// --- Lint rule Tests ---
+
+func TestModrinthPluginSource_ProjectStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/project/oldplugin" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"slug": "oldplugin", "status": "archived"}`))
+	}))
+	defer server.Close()
+
+	source := &ModrinthPluginSource{api: utils.Endpoint{URL: server.URL}}
+	resource := "oldplugin"
+
+	status, err := source.ProjectStatus(config.PluginConfig{Source: config.PluginSourceModrinth, Resource: &resource})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != "archived" {
+		t.Errorf("expected archived, got %q", status)
+	}
+}
+
+func TestPaperHangarPluginSource_CompatibleVersionsReadsEveryPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		var names []string
+		for i := offset; i < min(offset+25, 30); i++ {
+			names = append(names, fmt.Sprintf(`{"name": "v%d"}`, 30-i))
+		}
+		fmt.Fprintf(w, `{"pagination": {"count": 30, "offset": %d}, "result": [%s]}`, offset, strings.Join(names, ","))
+	}))
+	defer server.Close()
+
+	source := &PaperHangarPluginSource{api: utils.Endpoint{URL: server.URL}}
+	resource := "paged-plugin"
+	versions, err := source.CompatibleVersions(config.PluginConfig{Source: config.PluginSourcePaperHangar, Resource: &resource}, "1.21.8")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(versions) != 30 || versions[0] != "v30" || versions[29] != "v1" {
+		t.Errorf("expected all 30 versions newest first, got %v", versions)
+	}
+}
+
+func TestLintRules_NetworkRulesRegistered(t *testing.T) {
+	ids := map[string]bool{}
+	for _, rule := range config.LintRules() {
+		ids[rule.ID] = true
+	}
+
+	for _, id := range []string{"mc-incompatible", "archived-project"} {
+		if !ids[id] {
+			t.Errorf("expected rule %s to be registered", id)
+		}
+	}
+}
+
+func TestConfigure_SendsSourceCredentialsToBaseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "mrp_secret" || r.Header.Get("X-Team") != "infra" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"slug": "privateplugin", "status": "approved"}`))
+	}))
+	defer server.Close()
+
+	Configure(&config.PlugstepConfig{Sources: map[string]config.SourceConfig{
+		"modrinth": {BaseURL: server.URL + "/", Token: "mrp_secret", Headers: map[string]string{"X-Team": "infra"}},
+	}})
+	t.Cleanup(func() { Configure(&config.PlugstepConfig{}) })
+
+	resource := "privateplugin"
+	status, err := GetSource(config.PluginSourceModrinth).(StatusChecker).ProjectStatus(config.PluginConfig{Source: config.PluginSourceModrinth, Resource: &resource})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != "approved" {
+		t.Errorf("expected approved, got %q", status)
+	}
+}
+
+func TestConfigure_HangarExchangesAPIKey(t *testing.T) {
+	authentications := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/authenticate" && r.Method == http.MethodPost && r.URL.Query().Get("apiKey") == "hangar-key":
+			authentications++
+			w.Write([]byte(`{"token": "session-jwt", "expiresIn": 600000}`))
+		case r.Header.Get("Authorization") == "HangarAuth session-jwt":
+			w.Write([]byte("1.2.3"))
+		default:
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	Configure(&config.PlugstepConfig{Sources: map[string]config.SourceConfig{
+		"paper-hangar": {BaseURL: server.URL, APIKey: "hangar-key"},
+	}})
+	t.Cleanup(func() { Configure(&config.PlugstepConfig{}) })
+
+	source := GetSource(config.PluginSourcePaperHangar).(*PaperHangarPluginSource)
+	for _, resource := range []string{"PrivateA", "PrivateB"} {
+		version, err := source.getLatestVersion(resource)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if version != "1.2.3" {
+			t.Errorf("expected 1.2.3, got %q", version)
+		}
+	}
+	if authentications != 1 {
+		t.Errorf("expected the session to be reused, got %d authentications", authentications)
+	}
+}
+
+func TestConfigure_FallsBackFromMirrorToBaseURL(t *testing.T) {
+	var mirrorAuthorized bool
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorAuthorized = r.Header.Get("Authorization") == "mrp_secret"
+		http.Error(w, "warming up", http.StatusServiceUnavailable)
+	}))
+	defer mirror.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"slug": "mirrored", "status": "archived"}`))
+	}))
+	defer upstream.Close()
+
+	Configure(&config.PlugstepConfig{Sources: map[string]config.SourceConfig{
+		"modrinth": {BaseURL: upstream.URL, Mirrors: []string{mirror.URL}, Token: "mrp_secret"},
+	}})
+	t.Cleanup(func() { Configure(&config.PlugstepConfig{}) })
+
+	resource := "mirrored"
+	status, err := GetSource(config.PluginSourceModrinth).(StatusChecker).ProjectStatus(config.PluginConfig{Source: config.PluginSourceModrinth, Resource: &resource})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != "archived" {
+		t.Errorf("expected the upstream status, got %q", status)
+	}
+	if !mirrorAuthorized {
+		t.Error("expected the source's token to be sent to its mirror")
+	}
+}
+
+func TestConfigure_AppliesSourceCacheTTLs(t *testing.T) {
+	Configure(&config.PlugstepConfig{
+		Cache:   config.CacheConfig{Namespaces: map[string]string{"plugins": "1h"}},
+		Sources: map[string]config.SourceConfig{"modrinth": {CacheTTL: "1d"}},
+	})
+	t.Cleanup(func() { Configure(&config.PlugstepConfig{}) })
+
+	if ttl := GetSource(config.PluginSourceModrinth).(*ModrinthPluginSource).ttl; ttl != 24*time.Hour {
+		t.Errorf("expected the source's cache_ttl, got %v", ttl)
+	}
+	if ttl := GetSource(config.PluginSourcePaperHangar).(*PaperHangarPluginSource).ttl; ttl != time.Hour {
+		t.Errorf("expected the plugins namespace TTL, got %v", ttl)
+	}
+}
+
+func TestInstallPlugins_OfflineUsesCacheAndStore(t *testing.T) {
+	jar := []byte("cached plugin")
+	sum := sha512.Sum512(jar)
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/project/cached/version":
+			fmt.Fprintf(w, `[{"version_number": "1.0", "files": [{"url": "%s/cached.jar", "primary": true, "hashes": {"sha512": "%x"}}]}]`, serverURL, sum)
+		case "/cached.jar":
+			w.Write(jar)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	serverURL = server.URL
+
+	dir := t.TempDir()
+	if err := utils.InitCacheDB(dir); err != nil {
+		t.Fatal(err)
+	}
+	utils.StoreDirectory = t.TempDir()
+	Configure(&config.PlugstepConfig{Sources: map[string]config.SourceConfig{"modrinth": {BaseURL: server.URL}}})
+	t.Cleanup(func() {
+		utils.Offline = false
+		utils.StoreDirectory = ""
+		utils.CloseCache()
+		Configure(&config.PlugstepConfig{})
+	})
+
+	cached, uncached := "cached", "uncached"
+	ps := &plugstep.Plugstep{
+		ServerDirectory: dir,
+		Config: &config.PlugstepConfig{Plugins: []config.PluginConfig{
+			{Source: config.PluginSourceModrinth, Resource: &cached},
+		}},
+		Options: plugstep.Options{Output: output.ModePlain},
+	}
+	if err := InstallPlugins(ps); err != nil {
+		t.Fatalf("online install failed: %v", err)
+	}
+
+	server.Close()
+	utils.Offline = true
+	os.Remove(filepath.Join(dir, "plugins", "cached.jar"))
+	ps.Config.Plugins = append(ps.Config.Plugins, config.PluginConfig{Source: config.PluginSourceModrinth, Resource: &uncached})
+
+	err := InstallPlugins(ps)
+
+	if code := exitcode.Of(err); code != exitcode.Partial {
+		t.Errorf("expected partial exit code, got %d (%v)", code, err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "uncached") || strings.Contains(err.Error(), "\n  cached:") {
+		t.Errorf("expected only the uncached plugin to be listed, got %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "plugins", "cached.jar")); err != nil || !bytes.Equal(content, jar) {
+		t.Errorf("expected the cached plugin to be installed from the store, got %q (%v)", content, err)
+	}
+}
+
+func TestCustomPluginSource_RelativeDownloadURL(t *testing.T) {
+	resource := "private"
+	downloadURL := "releases/private.jar"
+	plugin := config.PluginConfig{Source: config.PluginSourceCustom, Resource: &resource, DownloadURL: &downloadURL}
+
+	download, err := (&CustomPluginSource{baseURL: "https://repo.example.com/plugins"}).GetPluginDownload(plugin)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if download.URL != "https://repo.example.com/plugins/releases/private.jar" {
+		t.Errorf("unexpected URL %q", download.URL)
+	}
+}
+
 // =============================================================================
 // Network Tests - These hit real APIs
 // =============================================================================
@@ -324,7 +607,7 @@ func TestCustomPluginSource_GetPluginDownload_EmptyStringURL(t *testing.T) {
 
 func TestModrinthPluginSource_GetPluginDownload_LatestVersion(t *testing.T) {
 	source := &ModrinthPluginSource{
-		apiURL: "https://api.modrinth.com/v2",
+		api: utils.Endpoint{URL: "https://api.modrinth.com/v2"},
 	}
 	resource := "chunky"
 	pluginConfig := config.PluginConfig{
@@ -357,7 +640,7 @@ func TestModrinthPluginSource_GetPluginDownload_LatestVersion(t *testing.T) {
 
 func TestModrinthPluginSource_GetPluginDownload_PinnedVersion(t *testing.T) {
 	source := &ModrinthPluginSource{
-		apiURL: "https://api.modrinth.com/v2",
+		api: utils.Endpoint{URL: "https://api.modrinth.com/v2"},
 	}
 	resource := "chunky"
 	version := "1.4.16"
@@ -388,7 +671,7 @@ func TestModrinthPluginSource_GetPluginDownload_PinnedVersion(t *testing.T) {
 
 func TestModrinthPluginSource_GetPluginDownload_NonexistentPlugin(t *testing.T) {
 	source := &ModrinthPluginSource{
-		apiURL: "https://api.modrinth.com/v2",
+		api: utils.Endpoint{URL: "https://api.modrinth.com/v2"},
 	}
 	resource := "this-plugin-definitely-does-not-exist-12345"
 	pluginConfig := config.PluginConfig{
@@ -406,7 +689,7 @@ func TestModrinthPluginSource_GetPluginDownload_NonexistentPlugin(t *testing.T)
 
 func TestModrinthPluginSource_GetPluginDownload_NonexistentVersion(t *testing.T) {
 	source := &ModrinthPluginSource{
-		apiURL: "https://api.modrinth.com/v2",
+		api: utils.Endpoint{URL: "https://api.modrinth.com/v2"},
 	}
 	resource := "chunky"
 	version := "99.99.99"
@@ -426,7 +709,7 @@ func TestModrinthPluginSource_GetPluginDownload_NonexistentVersion(t *testing.T)
 func TestModrinthPluginSource_GetPluginDownload_EmptyStringVersion(t *testing.T) {
 	// Edge case: Version is pointer to empty string (treated as unpinned/latest)
 	source := &ModrinthPluginSource{
-		apiURL: "https://api.modrinth.com/v2",
+		api: utils.Endpoint{URL: "https://api.modrinth.com/v2"},
 	}
 	resource := "chunky"
 	emptyVersion := ""
@@ -454,7 +737,7 @@ func TestModrinthPluginSource_GetPluginDownload_EmptyStringVersion(t *testing.T)
 
 func TestPaperHangarPluginSource_GetPluginDownload_LatestVersion(t *testing.T) {
 	source := &PaperHangarPluginSource{
-		apiURL: "https://hangar.papermc.io/api/v1",
+		api: utils.Endpoint{URL: "https://hangar.papermc.io/api/v1"},
 	}
 	resource := "ViaVersion"
 	pluginConfig := config.PluginConfig{
@@ -487,7 +770,7 @@ func TestPaperHangarPluginSource_GetPluginDownload_LatestVersion(t *testing.T) {
 
 func TestPaperHangarPluginSource_GetPluginDownload_PinnedVersion(t *testing.T) {
 	source := &PaperHangarPluginSource{
-		apiURL: "https://hangar.papermc.io/api/v1",
+		api: utils.Endpoint{URL: "https://hangar.papermc.io/api/v1"},
 	}
 	resource := "ViaVersion"
 	version := "5.0.3"
@@ -518,7 +801,7 @@ func TestPaperHangarPluginSource_GetPluginDownload_PinnedVersion(t *testing.T) {
 
 func TestPaperHangarPluginSource_GetPluginDownload_NonexistentPlugin(t *testing.T) {
 	source := &PaperHangarPluginSource{
-		apiURL: "https://hangar.papermc.io/api/v1",
+		api: utils.Endpoint{URL: "https://hangar.papermc.io/api/v1"},
 	}
 	resource := "ThisPluginDefinitelyDoesNotExist12345"
 	pluginConfig := config.PluginConfig{
@@ -536,7 +819,7 @@ func TestPaperHangarPluginSource_GetPluginDownload_NonexistentPlugin(t *testing.
 
 func TestPaperHangarPluginSource_GetPluginDownload_NonexistentVersion(t *testing.T) {
 	source := &PaperHangarPluginSource{
-		apiURL: "https://hangar.papermc.io/api/v1",
+		api: utils.Endpoint{URL: "https://hangar.papermc.io/api/v1"},
 	}
 	resource := "ViaVersion"
 	version := "99.99.99"
@@ -556,7 +839,7 @@ func TestPaperHangarPluginSource_GetPluginDownload_NonexistentVersion(t *testing
 func TestPaperHangarPluginSource_GetPluginDownload_EmptyStringVersion(t *testing.T) {
 	// Edge case: Version is pointer to empty string (treated as unpinned/latest)
 	source := &PaperHangarPluginSource{
-		apiURL: "https://hangar.papermc.io/api/v1",
+		api: utils.Endpoint{URL: "https://hangar.papermc.io/api/v1"},
 	}
 	resource := "ViaVersion"
 	emptyVersion := ""
@@ -579,3 +862,175 @@ func TestPaperHangarPluginSource_GetPluginDownload_EmptyStringVersion(t *testing
 
 	t.Logf("Hangar empty string version (treated as latest): version=%s", download.Version)
 }
+
+// --- filterModrinthVersions() Tests ---
+
+func TestFilterModrinthVersions_ReturnsMatchingVersionsInOrder(t *testing.T) {
+	versions := []ModrinthVersion{
+		{VersionNumber: "3.0.0", GameVersions: []string{"1.21.7", "1.21.8"}},
+		{VersionNumber: "2.0.0", GameVersions: []string{"1.21.4"}},
+		{VersionNumber: "1.0.0", GameVersions: []string{"1.21.4", "1.21.8"}},
+	}
+
+	result := filterModrinthVersions(versions, "1.21.8")
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 versions, got %d: %v", len(result), result)
+	}
+	if result[0] != "3.0.0" || result[1] != "1.0.0" {
+		t.Errorf("expected [3.0.0 1.0.0], got %v", result)
+	}
+}
+
+func TestFilterModrinthVersions_ReturnsNilWhenNoneMatch(t *testing.T) {
+	versions := []ModrinthVersion{
+		{VersionNumber: "1.0.0", GameVersions: []string{"1.20.4"}},
+	}
+
+	result := filterModrinthVersions(versions, "1.21.8")
+
+	if result != nil {
+		t.Errorf("expected nil, got %v", result)
+	}
+}
+
+// --- oldPluginFiles() Tests ---
+
+func TestOldPluginFiles_ListsUnconfiguredFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	pluginsDir := filepath.Join(tempDir, "plugins")
+	if err := os.MkdirAll(filepath.Join(pluginsDir, "LuckPerms"), 0755); err != nil {
+		t.Fatalf("failed to create plugins dir: %v", err)
+	}
+	for _, name := range []string{"luckperms.jar", "old.jar"} {
+		if err := os.WriteFile(filepath.Join(pluginsDir, name), []byte("jar"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	resource := "luckperms"
+	ps := &plugstep.Plugstep{
+		ServerDirectory: tempDir,
+		Config: &config.PlugstepConfig{
+			Plugins: []config.PluginConfig{
+				{Source: config.PluginSourceModrinth, Resource: &resource},
+			},
+		},
+	}
+
+	files, err := oldPluginFiles(ps)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 || files[0] != "old.jar" {
+		t.Errorf("expected [old.jar], got %v", files)
+	}
+}
+
+// --- removableFiles() Tests ---
+
+func TestRemovableFiles_OnlyManagedUnlessPruning(t *testing.T) {
+	tempDir := t.TempDir()
+	pluginsDir := filepath.Join(tempDir, "plugins")
+	if err := os.MkdirAll(pluginsDir, 0755); err != nil {
+		t.Fatalf("failed to create plugins dir: %v", err)
+	}
+	for _, name := range []string{"managed.jar", "handplaced.jar"} {
+		if err := os.WriteFile(filepath.Join(pluginsDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	m, _ := manifest.Load(tempDir)
+	if err := m.Record("plugins/managed.jar", manifest.Entry{Resource: "managed"}); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+
+	ps := &plugstep.Plugstep{
+		ServerDirectory: tempDir,
+		Config:          &config.PlugstepConfig{},
+	}
+
+	files, err := removableFiles(ps, m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 || files[0] != "managed.jar" {
+		t.Errorf("expected [managed.jar], got %v", files)
+	}
+
+	ps.Options.PruneUnmanaged = true
+	files, err = removableFiles(ps, m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected both files when pruning unmanaged, got %v", files)
+	}
+}
+
+// --- PluginPlan.Report() Tests ---
+
+func TestPluginPlanReport_Statuses(t *testing.T) {
+	tempDir := t.TempDir()
+	resource := "luckperms"
+	pinned := "5.4"
+	plugin := &config.PluginConfig{Source: config.PluginSourceModrinth, Resource: &resource, Version: &pinned}
+	file := filepath.Join(tempDir, "plugins", "luckperms.jar")
+	download := &PluginDownload{Version: "5.4", Checksum: "abc", ChecksumType: ChecksumTypeSha512}
+
+	report := PluginPlan{Plugin: plugin, Download: download, File: file}.Report(tempDir)
+	if report.Status != output.StatusMissing {
+		t.Errorf("expected missing, got %s", report.Status)
+	}
+	if report.File != "plugins/luckperms.jar" {
+		t.Errorf("expected relative file, got %s", report.File)
+	}
+	if report.PinnedVersion == nil || *report.PinnedVersion != "5.4" {
+		t.Errorf("expected pinned version 5.4, got %v", report.PinnedVersion)
+	}
+	if report.ResolvedVersion != "5.4" || report.Checksum != "abc" || report.ChecksumType != "sha512" {
+		t.Errorf("unexpected download fields: %+v", report)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatalf("failed to create plugins dir: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to write jar: %v", err)
+	}
+	if report := (PluginPlan{Plugin: plugin, Download: download, File: file}.Report(tempDir)); report.Status != output.StatusOutdated {
+		t.Errorf("expected outdated, got %s", report.Status)
+	}
+	if report := (PluginPlan{Plugin: plugin, Download: download, File: file, UpToDate: true}.Report(tempDir)); report.Status != output.StatusUpToDate {
+		t.Errorf("expected up-to-date, got %s", report.Status)
+	}
+
+	report = PluginPlan{Plugin: plugin, File: file, Err: errors.New("boom")}.Report(tempDir)
+	if report.Status != output.StatusFailed || report.Error != "boom" {
+		t.Errorf("expected failed with error, got %+v", report)
+	}
+}
+
+func TestPluginPlanReport_UnpinnedIsNull(t *testing.T) {
+	resource := "essentials"
+	empty := ""
+	plugin := &config.PluginConfig{Source: config.PluginSourceModrinth, Resource: &resource, Version: &empty}
+
+	report := PluginPlan{Plugin: plugin, File: "plugins/essentials.jar"}.Report("")
+	if report.PinnedVersion != nil {
+		t.Errorf("expected nil pinned version, got %v", *report.PinnedVersion)
+	}
+}
+
+func TestPluginPlanReport_UnverifiedCustomPluginHasNoChecksum(t *testing.T) {
+	resource := "unverified"
+	plugin := &config.PluginConfig{Source: config.PluginSourceCustom, Resource: &resource}
+	download := &PluginDownload{URL: "https://example.com/unverified.jar", Checksum: noChecksum, ChecksumType: ChecksumTypeSha256}
+
+	report := PluginPlan{Plugin: plugin, Download: download, File: "plugins/unverified.jar"}.Report("")
+	if report.Checksum != "" || report.ChecksumType != "" {
+		t.Errorf("expected no checksum, got %q %q", report.ChecksumType, report.Checksum)
+	}
+}
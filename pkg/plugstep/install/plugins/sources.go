@@ -1,13 +1,33 @@
 package plugins
 
 import (
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 )
 
 type PluginSource interface {
 	GetPluginDownload(c config.PluginConfig) (*PluginDownload, error)
 }
 
+// CompatibilityChecker is implemented by sources that publish which
+// Minecraft versions each plugin version supports.
+type CompatibilityChecker interface {
+	CompatibleVersions(c config.PluginConfig, minecraftVersion string) ([]string, error)
+}
+
+// StatusChecker is implemented by sources that mark projects as archived or
+// otherwise no longer maintained.
+type StatusChecker interface {
+	// ProjectStatus returns the source's status of the project, e.g.
+	// "approved" or "archived".
+	ProjectStatus(c config.PluginConfig) (string, error)
+}
+
 type ChecksumType string
 
 const (
@@ -26,14 +46,121 @@ func GetSource(source config.PluginSource) PluginSource {
 	switch source {
 	case config.PluginSourceModrinth:
 		return &ModrinthPluginSource{
-			apiURL: "https://api.modrinth.com/v2",
+			api: Endpoint(source),
+			ttl: cacheTTL(source),
 		}
 	case config.PluginSourcePaperHangar:
 		return &PaperHangarPluginSource{
-			apiURL: "https://hangar.papermc.io/api/v1",
+			api: Endpoint(source),
+			ttl: cacheTTL(source),
 		}
 	case config.PluginSourceCustom:
-		return &CustomPluginSource{}
+		return &CustomPluginSource{
+			baseURL: Endpoint(source).URL,
+		}
 	}
 	return nil
 }
+
+var (
+	endpointsMu sync.RWMutex
+	endpoints   = map[config.PluginSource]utils.Endpoint{}
+	ttls        = map[config.PluginSource]time.Duration{}
+)
+
+// Configure applies the [sources] of a config: API URLs, mirrors and cache
+// TTLs for GetSource and search, and the headers and credentials sent to each
+// source.
+func Configure(cfg *config.PlugstepConfig) {
+	endpointsMu.Lock()
+	endpoints = map[config.PluginSource]utils.Endpoint{}
+	ttls = map[config.PluginSource]time.Duration{}
+	for _, source := range config.SupportedSources {
+		endpoints[source] = cfg.SourceEndpoint(source)
+		ttls[source] = cfg.SourceCacheTTL(source)
+	}
+	endpointsMu.Unlock()
+
+	var rules []utils.RequestRule
+	for name, s := range cfg.Sources {
+		source := config.PluginSource(name)
+		for _, secret := range []string{s.Token, s.APIKey, s.Password} {
+			utils.RegisterSecret(secret)
+		}
+
+		headers := map[string]string{}
+		for header, value := range s.Headers {
+			headers[header] = value
+		}
+		rule := utils.RequestRule{Headers: headers, Username: s.Username, Password: s.Password}
+
+		switch source {
+		case config.PluginSourceModrinth:
+			if s.Token != "" {
+				headers["Authorization"] = s.Token
+			}
+		case config.PluginSourceCustom:
+			if s.Token != "" {
+				headers["Authorization"] = "Bearer " + s.Token
+			}
+		}
+
+		for _, prefix := range sourcePrefixes(cfg, source) {
+			rule.Prefix = prefix
+			if source == config.PluginSourcePaperHangar && s.APIKey != "" {
+				// Hangar sessions are per server, so every mirror authenticates itself.
+				rule.Authorize = hangarAuthorizer(prefix, s.APIKey)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	utils.SetRequestRules(rules)
+}
+
+// Endpoint returns the API of a source, for custom plugins the URL relative
+// download URLs are resolved against. Without Configure the defaults apply,
+// overridden by the environment.
+func Endpoint(source config.PluginSource) utils.Endpoint {
+	endpointsMu.RLock()
+	defer endpointsMu.RUnlock()
+	if endpoint, ok := endpoints[source]; ok {
+		return endpoint
+	}
+	return config.DefaultEndpoint(string(source))
+}
+
+// cacheTTL returns how long the API responses of a source are used before
+// they are revalidated.
+func cacheTTL(source config.PluginSource) time.Duration {
+	endpointsMu.RLock()
+	defer endpointsMu.RUnlock()
+	if ttl, ok := ttls[source]; ok {
+		return ttl
+	}
+	return config.DefaultCacheTTL
+}
+
+// sourcePrefixes returns the URLs below which a source's headers are sent:
+// its API and mirrors. Custom plugins without a base_url send them to the
+// sites of their download URLs.
+func sourcePrefixes(cfg *config.PlugstepConfig, source config.PluginSource) []string {
+	if urls := Endpoint(source).URLs(); len(urls) > 0 {
+		return urls
+	}
+
+	var prefixes []string
+	for _, p := range cfg.Plugins {
+		if p.Source != source || p.DownloadURL == nil {
+			continue
+		}
+		u, err := url.Parse(*p.DownloadURL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		prefix := u.Scheme + "://" + u.Host + "/"
+		if !slices.Contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
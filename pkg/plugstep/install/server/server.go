@@ -3,148 +3,16 @@ package server
 import (
 	"fmt"
 	"path/filepath"
-	"strings"
 
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/manifest"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
-	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 )
 
-type serverModel struct {
-	downloaded int64
-	total      int64
-	done       bool
-	err        error
-	status     string
-	progressCh <-chan progressUpdate
-}
-
-type progressUpdate struct {
-	downloaded int64
-	total      int64
-}
-
-type progressMsg progressUpdate
-type doneMsg struct{ err error }
-
-func (m serverModel) Init() tea.Cmd {
-	return waitForProgress(m.progressCh)
-}
-
-func waitForProgress(ch <-chan progressUpdate) tea.Cmd {
-	return func() tea.Msg {
-		p, ok := <-ch
-		if !ok {
-			return doneMsg{}
-		}
-		return progressMsg(p)
-	}
-}
-
-func (m serverModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case progressMsg:
-		m.downloaded = msg.downloaded
-		m.total = msg.total
-		m.status = "downloading"
-		return m, waitForProgress(m.progressCh)
-	case doneMsg:
-		m.done = true
-		m.err = msg.err
-		if m.err == nil {
-			m.status = "installed"
-		} else {
-			m.status = "failed"
-		}
-		return m, tea.Quit
-	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" {
-			return m, tea.Quit
-		}
-	}
-	return m, nil
-}
-
-func (m serverModel) View() string {
-	var b strings.Builder
-
-	badge := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#cdd6f4")).
-		PaddingRight(1).
-		Bold(true).
-		PaddingLeft(1).
-		Render("~")
-
-	background := "#f9e2af"
-	switch m.status {
-	case "downloading":
-		background = "#89b4fa"
-	case "installed":
-		background = "#a6e3a1"
-	case "failed":
-		background = "#f38ba8"
-	case "checked":
-		background = "#89dceb"
-	}
-
-	statusBadge := lipgloss.NewStyle().
-		Background(lipgloss.Color(background)).
-		Foreground(lipgloss.Color("#232634")).
-		PaddingRight(2).
-		PaddingLeft(2).
-		Transform(strings.ToUpper).
-		Render(m.status)
-
-	b.WriteString(fmt.Sprintf("%s%s server.jar ", badge, statusBadge))
-
-	if m.status == "downloading" {
-		b.WriteString(renderProgressBar(m.downloaded, m.total, 20))
-	}
-
-	b.WriteString("\n")
-	return b.String()
-}
-
-func renderProgressBar(downloaded, total int64, width int) string {
-	var percent float64
-	if total > 0 {
-		percent = float64(downloaded) / float64(total)
-	}
-
-	filled := int(percent * float64(width))
-	if filled > width {
-		filled = width
-	}
-
-	filledStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#a6e3a1"))
-	emptyStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#45475a"))
-
-	bar := filledStyle.Render(strings.Repeat("—", filled)) +
-		emptyStyle.Render(strings.Repeat("—", width-filled))
-
-	return fmt.Sprintf("[%s] %s/%s", bar, formatSize(downloaded), formatSize(total))
-}
-
-func formatSize(bytes int64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-	)
-	switch {
-	case bytes >= MB:
-		return fmt.Sprintf("%.1f MB", float64(bytes)/float64(MB))
-	case bytes >= KB:
-		return fmt.Sprintf("%.0f KB", float64(bytes)/float64(KB))
-	default:
-		return fmt.Sprintf("%d B", bytes)
-	}
-}
-
-func InstallServer(ps *plugstep.Plugstep) {
+func InstallServer(ps *plugstep.Plugstep) error {
 	var box = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#bac2de")).
 		PaddingLeft(4).
@@ -167,63 +35,137 @@ func InstallServer(ps *plugstep.Plugstep) {
 			key.Render("Server Minecraft version") + val.Render(ps.Config.Server.MinecraftVersion) + "\n" +
 			key.Render("Server version") + val.Render(ps.Config.Server.Version),
 	)
-	fmt.Println(b)
+	if ps.Options.Output.Resolve() == output.ModeTUI {
+		fmt.Println(b)
+	} else {
+		log.Info("Server config", "vendor", ps.Config.Server.Vendor, "project", ps.Config.Server.Project, "minecraft-version", ps.Config.Server.MinecraftVersion, "version", ps.Config.Server.Version)
+	}
+
+	stream := output.StartFor(ps.Options.Output, ps.Options.Member)
+	event := output.Event{
+		Kind:   output.KindServer,
+		Name:   "server.jar",
+		Source: string(ps.Config.Server.Vendor),
+		Status: output.StatusPreparing,
+	}
+	stream.Send(event)
+
+	finish := func(status output.Status, err error) {
+		event.Status = status
+		if err != nil {
+			event.Error = err.Error()
+		}
+		stream.Send(event)
+		if err := stream.Close(); err != nil {
+			log.Error("UI error", "err", err)
+		}
+	}
 
-	vendor, err := GetVendor(ps.Config.Server.Vendor)
+	plan, err := PlanServer(ps)
 	if err != nil {
-		log.Error("failed to get server vendor", "err", err)
-		return
+		finish(output.StatusFailed, err)
+		return err
+	}
+
+	if plan.UpToDate {
+		recordServerJar(ps)
+		finish(output.StatusChecked, nil)
+		log.Info("Checked server jar.")
+		return nil
+	}
+
+	if err := ps.Backup.Save("server.jar"); err != nil {
+		finish(output.StatusFailed, err)
+		return fmt.Errorf("failed to back up server jar: %w", err)
+	}
+
+	if b := ps.Options.Bundle; b != nil {
+		err = utils.InstallVerified(b.Path("sha256", plan.Download.Checksum), plan.File, "sha256", plan.Download.Checksum)
+	} else {
+		err = utils.DownloadVerified(plan.Download.URL, plan.File, "sha256", plan.Download.Checksum, func(downloaded, total int64) {
+			progress := event
+			progress.Status = output.StatusDownloading
+			progress.Downloaded = downloaded
+			progress.Total = total
+			stream.Progress(progress)
+		})
 	}
-	download, err := vendor.GetDownload(ps.Config.Server)
 	if err != nil {
-		log.Error(err)
-		return
+		finish(output.StatusFailed, err)
+		return fmt.Errorf("failed to download server jar: %w", err)
 	}
-	log.Debug("download found", "url", download.URL, "checksum", download.Checksum)
 
-	location := filepath.Join(ps.ServerDirectory, "server.jar")
+	recordServerJar(ps)
+	finish(output.StatusInstalled, nil)
+	log.Info("Downloaded server JAR successfully.")
+	return nil
+}
 
-	existingJarChecksum, err := utils.CalculateFileSHA256(location)
+func recordServerJar(ps *plugstep.Plugstep) {
+	m, err := manifest.Load(ps.ServerDirectory)
 	if err != nil {
-		log.Debug("failed to get checksum of current serverjar", "err", err)
+		log.Debug("failed to load manifest", "err", err)
+		return
 	}
 
-	if existingJarChecksum == download.Checksum {
-		log.Info("Checked server jar.")
+	entry := manifest.Entry{
+		Source:   string(ps.Config.Server.Vendor),
+		Resource: ps.Config.Server.Project,
+	}
+	if err := m.Record("server.jar", entry); err != nil {
+		log.Debug("failed to record server jar in manifest", "err", err)
 		return
 	}
 
-	progressCh := make(chan progressUpdate)
-	doneCh := make(chan error)
+	if err := m.Save(); err != nil {
+		log.Debug("failed to save manifest", "err", err)
+	}
+}
 
-	go func() {
-		err := utils.DownloadFileWithProgress(download.URL, location, func(downloaded, total int64) {
-			progressCh <- progressUpdate{downloaded: downloaded, total: total}
-		})
-		close(progressCh)
-		doneCh <- err
-	}()
+// ServerPlan describes what InstallServer would do.
+type ServerPlan struct {
+	Download *ServerJarDownload
+	File     string
+	UpToDate bool
+}
 
-	m := serverModel{
-		status:     "preparing",
-		progressCh: progressCh,
+// PlanServer resolves the server jar download and checks whether the jar
+// already on disk matches it, without downloading anything.
+func PlanServer(ps *plugstep.Plugstep) (*ServerPlan, error) {
+	download, err := resolveDownload(ps)
+	if err != nil {
+		return nil, err
 	}
+	log.Debug("download found", "url", download.URL, "checksum", download.Checksum)
 
-	finalModel, teaErr := tea.NewProgram(m).Run()
-	if teaErr != nil {
-		log.Error("UI error", "err", teaErr)
-		return
+	location := filepath.Join(ps.ServerDirectory, "server.jar")
+
+	existingJarChecksum, err := utils.CalculateFileSHA256(location)
+	if err != nil {
+		log.Debug("failed to get checksum of current serverjar", "err", err)
 	}
 
-	downloadErr := <-doneCh
-	fm := finalModel.(serverModel)
+	return &ServerPlan{
+		Download: download,
+		File:     location,
+		UpToDate: existingJarChecksum == download.Checksum,
+	}, nil
+}
 
-	if downloadErr != nil || fm.err != nil {
-		if downloadErr != nil {
-			log.Error("failed to download server jar", "err", downloadErr)
+// resolveDownload resolves the server jar through its vendor, or from the
+// bundle an install reads from.
+func resolveDownload(ps *plugstep.Plugstep) (*ServerJarDownload, error) {
+	if b := ps.Options.Bundle; b != nil {
+		bundled, err := b.FindServer(ps.Config.Server)
+		if err != nil {
+			return nil, err
 		}
-		return
+		return &ServerJarDownload{URL: bundled.URL, Checksum: bundled.Checksum, Build: bundled.Build}, nil
 	}
 
-	log.Info("Downloaded server JAR successfully.")
+	vendor, err := GetVendor(ps.Config.Server.Vendor, ps.Config.VendorEndpoint(ps.Config.Server.Vendor), ps.Config.VendorCacheTTL(ps.Config.Server.Vendor))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server vendor: %w", err)
+	}
+	return vendor.GetDownload(ps.Config.Server)
 }
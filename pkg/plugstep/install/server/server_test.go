@@ -8,8 +8,10 @@ import (
 
 // --- GetVendor() Tests ---
 
+var paperMC = config.DefaultEndpoint(string(config.ServerJarVendorPaperMC))
+
 func TestGetVendor_ReturnsPaperMCVendor(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -24,13 +26,13 @@ func TestGetVendor_ReturnsPaperMCVendor(t *testing.T) {
 	}
 
 	// Verify API URL is set (we'll test if it's correct via network tests)
-	if paper.apiURL == "" {
+	if paper.api.URL == "" {
 		t.Error("expected non-empty API URL")
 	}
 }
 
 func TestGetVendor_ReturnsErrorForUnknownVendor(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendor("unknown-vendor"))
+	vendor, err := GetVendor(config.ServerJarVendor("unknown-vendor"), paperMC, config.DefaultCacheTTL)
 
 	if err == nil {
 		t.Error("expected error for unknown vendor")
@@ -41,7 +43,7 @@ func TestGetVendor_ReturnsErrorForUnknownVendor(t *testing.T) {
 }
 
 func TestGetVendor_ReturnsErrorForEmptyVendor(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendor(""))
+	vendor, err := GetVendor(config.ServerJarVendor(""), paperMC, config.DefaultCacheTTL)
 
 	if err == nil {
 		t.Error("expected error for empty vendor")
@@ -56,7 +58,7 @@ func TestGetVendor_ReturnsErrorForEmptyVendor(t *testing.T) {
 // =============================================================================
 
 func TestPaperJarVendor_GetDownload_ValidVersion(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -85,7 +87,7 @@ func TestPaperJarVendor_GetDownload_ValidVersion(t *testing.T) {
 }
 
 func TestPaperJarVendor_GetDownload_LatestBuild(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -115,7 +117,7 @@ func TestPaperJarVendor_GetDownload_LatestBuild(t *testing.T) {
 }
 
 func TestPaperJarVendor_GetDownload_NonexistentVersion(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -135,7 +137,7 @@ func TestPaperJarVendor_GetDownload_NonexistentVersion(t *testing.T) {
 }
 
 func TestPaperJarVendor_GetDownload_NonexistentProject(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -155,7 +157,7 @@ func TestPaperJarVendor_GetDownload_NonexistentProject(t *testing.T) {
 }
 
 func TestPaperJarVendor_GetDownload_NonexistentMinecraftVersion(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -177,7 +179,7 @@ func TestPaperJarVendor_GetDownload_NonexistentMinecraftVersion(t *testing.T) {
 func TestPaperJarVendor_GetDownload_VelocityProject(t *testing.T) {
 	// Velocity is another PaperMC project (proxy server)
 	// Note: Velocity uses its own version scheme, not Minecraft versions
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -204,7 +206,7 @@ func TestPaperJarVendor_GetDownload_VelocityProject(t *testing.T) {
 
 func TestPaperJarVendor_GetDownload_FoliaProject(t *testing.T) {
 	// Folia is another PaperMC project (regionized multithreading)
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -230,7 +232,7 @@ func TestPaperJarVendor_GetDownload_FoliaProject(t *testing.T) {
 }
 
 func TestPaperJarVendor_GetDownload_EmptyProject(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -250,7 +252,7 @@ func TestPaperJarVendor_GetDownload_EmptyProject(t *testing.T) {
 }
 
 func TestPaperJarVendor_GetDownload_EmptyMinecraftVersion(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -270,7 +272,7 @@ func TestPaperJarVendor_GetDownload_EmptyMinecraftVersion(t *testing.T) {
 }
 
 func TestPaperJarVendor_GetDownload_EmptyVersion(t *testing.T) {
-	vendor, err := GetVendor(config.ServerJarVendorPaperMC)
+	vendor, err := GetVendor(config.ServerJarVendorPaperMC, paperMC, config.DefaultCacheTTL)
 	if err != nil {
 		t.Fatalf("failed to get vendor: %v", err)
 	}
@@ -3,6 +3,9 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
@@ -18,10 +21,25 @@ type ServerJarVendor interface {
 type ServerJarDownload struct {
 	URL      string `json:"url"`
 	Checksum string `json:"checksum"`
+	// Build is the build the configured version resolved to.
+	Build string `json:"build,omitempty"`
 }
 
 type PaperJarVendor struct {
-	apiURL string
+	api utils.Endpoint
+	// ttl is how long the latest build is used before it is revalidated,
+	// pinned builds are cached forever.
+	ttl time.Duration
+}
+
+type paperBuild struct {
+	ID        int `json:"id"`
+	Downloads map[string]struct {
+		Url       string `json:"url"`
+		Checksums struct {
+			Sha256 string `json:"sha256"`
+		} `json:"checksums"`
+	} `json:"downloads"`
 }
 
 func initCache() *utils.Cache {
@@ -29,31 +47,15 @@ func initCache() *utils.Cache {
 }
 
 func (p *PaperJarVendor) GetDownload(cfg config.ServerConfig) (*ServerJarDownload, error) {
-	cache := initCache()
 	cacheKey := fmt.Sprintf("paper:%s:%s:%s", cfg.Project, cfg.MinecraftVersion, cfg.Version)
-
-	var cached ServerJarDownload
-	if cache != nil && cache.Get(cacheKey, &cached) {
-		return &cached, nil
+	ttl := p.ttl
+	if cfg.Version != "latest" {
+		ttl = utils.CacheTTLForever
 	}
 
-	r, err := utils.HTTPClient.Get(fmt.Sprintf("%s/v3/projects/%s/versions/%s/builds/%s", p.apiURL, cfg.Project, cfg.MinecraftVersion, cfg.Version))
-	if err != nil {
-		return nil, err
-	}
-	defer r.Body.Close()
-
-	var response struct {
-		Downloads map[string]struct {
-			Url       string `json:"url"`
-			Checksums struct {
-				Sha256 string `json:"sha256"`
-			} `json:"checksums"`
-		} `json:"downloads"`
-	}
-
-	err = json.NewDecoder(r.Body).Decode(&response)
-	if err != nil {
+	var response paperBuild
+	path := fmt.Sprintf("/v3/projects/%s/versions/%s/builds/%s", cfg.Project, cfg.MinecraftVersion, cfg.Version)
+	if err := utils.CachedGet(initCache(), cacheKey, ttl, p.api, path, &response, decodeBuild); err != nil {
 		return nil, err
 	}
 
@@ -63,23 +65,34 @@ func (p *PaperJarVendor) GetDownload(cfg config.ServerConfig) (*ServerJarDownloa
 		return nil, fmt.Errorf("no server download avaliable for version")
 	}
 
-	jar := ServerJarDownload{
+	build := cfg.Version
+	if response.ID > 0 {
+		build = strconv.Itoa(response.ID)
+	}
+
+	return &ServerJarDownload{
 		URL:      download.Url,
 		Checksum: download.Checksums.Sha256,
-	}
+		Build:    build,
+	}, nil
+}
 
-	if cache != nil {
-		cache.SetPermanent(cacheKey, jar)
+func decodeBuild(r *http.Response, dest interface{}) error {
+	if r.StatusCode != 200 {
+		return fmt.Errorf("got %d from %s", r.StatusCode, r.Request.URL)
 	}
-
-	return &jar, nil
+	return json.NewDecoder(r.Body).Decode(dest)
 }
 
-func GetVendor(vendor config.ServerJarVendor) (ServerJarVendor, error) {
+// GetVendor returns the vendor reached through api, see
+// config.PlugstepConfig.VendorEndpoint, whose latest builds are revalidated
+// after ttl.
+func GetVendor(vendor config.ServerJarVendor, api utils.Endpoint, ttl time.Duration) (ServerJarVendor, error) {
 	switch vendor {
 	case config.ServerJarVendorPaperMC:
 		return &PaperJarVendor{
-			apiURL: "https://fill.papermc.io",
+			api: api,
+			ttl: ttl,
 		}, nil
 	}
 	return nil, fmt.Errorf("unknown server vendor: %s", vendor)
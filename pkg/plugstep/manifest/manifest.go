@@ -0,0 +1,158 @@
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+)
+
+const fileName = "manifest.json"
+
+// Entry records a single file Plugstep wrote into the server directory.
+type Entry struct {
+	SHA256   string `json:"sha256"`
+	Source   string `json:"source,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+// Manifest tracks the files Plugstep installed, keyed by their slash separated
+// path relative to the server directory (e.g. "plugins/luckperms.jar").
+type Manifest struct {
+	Files map[string]Entry `json:"files"`
+
+	serverDirectory string
+	mu              sync.Mutex
+}
+
+// Path returns the location of the manifest for a server directory.
+func Path(serverDirectory string) string {
+	return filepath.Join(serverDirectory, ".plugstep", fileName)
+}
+
+// Load reads the manifest of a server directory. A missing manifest is not an
+// error and yields an empty one.
+func Load(serverDirectory string) (*Manifest, error) {
+	m := &Manifest{
+		Files:           map[string]Entry{},
+		serverDirectory: serverDirectory,
+	}
+
+	data, err := os.ReadFile(Path(serverDirectory))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Files == nil {
+		m.Files = map[string]Entry{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest back to .plugstep/manifest.json.
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path := Path(m.serverDirectory)
+	if err := utils.EnsureDirectory(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Record hashes the file at relPath and stores it in the manifest.
+func (m *Manifest) Record(relPath string, entry Entry) error {
+	hash, err := utils.CalculateFileSHA256(m.abs(relPath))
+	if err != nil {
+		return err
+	}
+	entry.SHA256 = hash
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files[filepath.ToSlash(relPath)] = entry
+	return nil
+}
+
+// Forget drops relPath from the manifest.
+func (m *Manifest) Forget(relPath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Files, filepath.ToSlash(relPath))
+}
+
+// Owns reports whether relPath was installed by Plugstep and is unchanged
+// since, so it is safe to delete.
+func (m *Manifest) Owns(relPath string) bool {
+	m.mu.Lock()
+	entry, ok := m.Files[filepath.ToSlash(relPath)]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	hash, err := utils.CalculateFileSHA256(m.abs(relPath))
+	if err != nil {
+		return false
+	}
+	return hash == entry.SHA256
+}
+
+// Managed reports whether relPath is listed in the manifest, regardless of
+// whether it changed on disk.
+func (m *Manifest) Managed(relPath string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.Files[filepath.ToSlash(relPath)]
+	return ok
+}
+
+// Prune drops entries whose files no longer exist and returns how many were
+// dropped.
+func (m *Manifest) Prune() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pruned := 0
+	for path := range m.Files {
+		if _, err := os.Stat(m.abs(path)); os.IsNotExist(err) {
+			delete(m.Files, path)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// FilesFor returns the paths recorded for a resource, sorted.
+func (m *Manifest) FilesFor(resource string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var files []string
+	for path, entry := range m.Files {
+		if entry.Resource == resource {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (m *Manifest) abs(relPath string) string {
+	return filepath.Join(m.serverDirectory, filepath.FromSlash(relPath))
+}
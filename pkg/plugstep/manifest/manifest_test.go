@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", rel, err)
+	}
+}
+
+func TestLoad_MissingManifestIsEmpty(t *testing.T) {
+	m, err := Load(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(m.Files) != 0 {
+		t.Errorf("expected empty manifest, got %d files", len(m.Files))
+	}
+}
+
+func TestRecord_SaveAndLoadRoundTrip(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, tempDir, "plugins/luckperms.jar", "jar")
+
+	m, _ := Load(tempDir)
+	if err := m.Record("plugins/luckperms.jar", Entry{Source: "modrinth", Resource: "luckperms"}); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := Load(tempDir)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	entry, ok := loaded.Files["plugins/luckperms.jar"]
+	if !ok {
+		t.Fatal("expected recorded file in loaded manifest")
+	}
+	if entry.Resource != "luckperms" || entry.SHA256 == "" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestOwns_FalseForUnmanagedFile(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, tempDir, "plugins/handplaced.jar", "jar")
+
+	m, _ := Load(tempDir)
+
+	if m.Owns("plugins/handplaced.jar") {
+		t.Error("expected unmanaged file not to be owned")
+	}
+}
+
+func TestOwns_FalseForModifiedFile(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, tempDir, "plugins/luckperms.jar", "jar")
+
+	m, _ := Load(tempDir)
+	if err := m.Record("plugins/luckperms.jar", Entry{Resource: "luckperms"}); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	if !m.Owns("plugins/luckperms.jar") {
+		t.Fatal("expected recorded file to be owned")
+	}
+
+	writeFile(t, tempDir, "plugins/luckperms.jar", "patched jar")
+
+	if m.Owns("plugins/luckperms.jar") {
+		t.Error("expected modified file not to be owned")
+	}
+	if !m.Managed("plugins/luckperms.jar") {
+		t.Error("expected modified file to still be managed")
+	}
+}
+
+func TestPrune_DropsMissingFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	writeFile(t, tempDir, "plugins/a.jar", "a")
+	writeFile(t, tempDir, "plugins/b.jar", "b")
+
+	m, _ := Load(tempDir)
+	m.Record("plugins/a.jar", Entry{Resource: "a"})
+	m.Record("plugins/b.jar", Entry{Resource: "b"})
+	os.Remove(filepath.Join(tempDir, "plugins", "b.jar"))
+
+	pruned := m.Prune()
+
+	if pruned != 1 {
+		t.Errorf("expected 1 pruned entry, got %d", pruned)
+	}
+	if got := m.FilesFor("a"); len(got) != 1 || got[0] != "plugins/a.jar" {
+		t.Errorf("expected a.jar to remain, got %v", got)
+	}
+}
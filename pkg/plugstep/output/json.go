@@ -0,0 +1,37 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// writeMu keeps the lines of streams rendering in parallel, e.g. for the
+// members of a workspace, from interleaving.
+var writeMu sync.Mutex
+
+// jsonRenderer writes newline-delimited JSON, one event per state transition.
+type jsonRenderer struct {
+	transitions
+	out io.Writer
+}
+
+func (r *jsonRenderer) Run(events <-chan Event) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	var err error
+	for e := range events {
+		if !r.changed(e) || err != nil {
+			continue
+		}
+		buf.Reset()
+		if err = encoder.Encode(e); err != nil {
+			continue
+		}
+		writeMu.Lock()
+		_, err = r.out.Write(buf.Bytes())
+		writeMu.Unlock()
+	}
+	return err
+}
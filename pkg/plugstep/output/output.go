@@ -0,0 +1,198 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Mode selects how install progress is rendered.
+type Mode string
+
+const (
+	ModeTUI   Mode = "tui"
+	ModePlain Mode = "plain"
+	ModeJSON  Mode = "json"
+)
+
+// ParseMode validates a --output value. An empty value means auto-detect.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeTUI, ModePlain, ModeJSON:
+		return Mode(s), nil
+	}
+	return "", fmt.Errorf("invalid output mode: %s (valid: plain, tui, json)", s)
+}
+
+// Detect picks the TUI when stdout is a terminal and plain output otherwise,
+// e.g. in CI logs or Docker builds.
+func Detect() Mode {
+	if isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return ModeTUI
+	}
+	return ModePlain
+}
+
+// Resolve returns m, or the detected mode when m is empty.
+func (m Mode) Resolve() Mode {
+	if m == "" {
+		return Detect()
+	}
+	return m
+}
+
+type Kind string
+
+const (
+	KindServer Kind = "server"
+	KindPlugin Kind = "plugin"
+)
+
+type Status string
+
+const (
+	StatusWaiting     Status = "waiting"
+	StatusPreparing   Status = "preparing"
+	StatusDownloading Status = "downloading"
+	StatusInstalled   Status = "installed"
+	StatusChecked     Status = "checked"
+	StatusFailed      Status = "failed"
+)
+
+// Event is a single state change (or download progress tick) of an item
+// being installed. Every renderer consumes the same stream of events.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Kind       Kind      `json:"kind"`
+	Name       string    `json:"name"`
+	Source     string    `json:"source,omitempty"`
+	Status     Status    `json:"status"`
+	Downloaded int64     `json:"downloaded,omitempty"`
+	Total      int64     `json:"total,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	// Plugin is attached to the final event of each plugin.
+	Plugin *PluginReport `json:"plugin,omitempty"`
+	// Server is the workspace member the event belongs to, when installing
+	// several servers at once.
+	Server string `json:"server,omitempty"`
+}
+
+// Renderer consumes events until the channel is closed.
+type Renderer interface {
+	Run(events <-chan Event) error
+}
+
+// NewRenderer returns the renderer for a mode, auto-detecting an empty one.
+func NewRenderer(mode Mode) Renderer {
+	switch mode.Resolve() {
+	case ModePlain:
+		return &plainRenderer{}
+	case ModeJSON:
+		return &jsonRenderer{out: os.Stdout}
+	}
+	return &tuiRenderer{}
+}
+
+// Stream runs a renderer in the background. Send events to it and call Close
+// once the install is finished to wait for the renderer to flush.
+type Stream struct {
+	events chan Event
+	done   chan error
+	server string
+}
+
+func Start(mode Mode) *Stream {
+	return StartFor(mode, "")
+}
+
+// StartFor starts a stream whose events are tagged with a workspace member,
+// so the output of servers installed in parallel can be told apart.
+func StartFor(mode Mode, server string) *Stream {
+	s := &Stream{
+		events: make(chan Event, 100),
+		done:   make(chan error, 1),
+		server: server,
+	}
+	renderer := NewRenderer(mode)
+	go func() {
+		s.done <- renderer.Run(s.events)
+	}()
+	return s
+}
+
+// Send delivers a state change. It blocks until the renderer has room, so
+// state changes are never dropped.
+func (s *Stream) Send(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Server == "" {
+		e.Server = s.server
+	}
+	s.events <- e
+}
+
+// Progress delivers a download progress tick, dropping it if the renderer is
+// busy.
+func (s *Stream) Progress(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Server == "" {
+		e.Server = s.server
+	}
+	select {
+	case s.events <- e:
+	default:
+	}
+}
+
+// Close ends the stream and waits for the renderer to finish.
+func (s *Stream) Close() error {
+	close(s.events)
+	return <-s.done
+}
+
+// transitions filters a stream down to state changes, dropping repeated
+// progress ticks of an item that is already downloading.
+type transitions struct {
+	last map[string]Status
+}
+
+func (t *transitions) changed(e Event) bool {
+	if t.last == nil {
+		t.last = map[string]Status{}
+	}
+	key := string(e.Kind) + ":" + e.Name
+	if t.last[key] == e.Status {
+		return false
+	}
+	t.last[key] = e.Status
+	return true
+}
+
+// Statuses reported for plugins that are inspected rather than installed.
+const (
+	StatusUpToDate Status = "up-to-date"
+	StatusOutdated Status = "outdated"
+	StatusMissing  Status = "missing"
+)
+
+// PluginReport is the stable machine-readable description of a configured
+// plugin, shared by `plugin list --json` and `install --json`. See
+// docs/json-output.md before changing it.
+type PluginReport struct {
+	Source          string  `json:"source"`
+	Resource        string  `json:"resource"`
+	PinnedVersion   *string `json:"pinned_version"`
+	ResolvedVersion string  `json:"resolved_version,omitempty"`
+	Checksum        string  `json:"checksum,omitempty"`
+	ChecksumType    string  `json:"checksum_type,omitempty"`
+	Status          Status  `json:"status"`
+	File            string  `json:"file"`
+	Error           string  `json:"error,omitempty"`
+	Profile         string  `json:"profile,omitempty"`
+	IncludedFrom    string  `json:"included_from,omitempty"`
+}
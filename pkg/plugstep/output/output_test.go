@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseMode_AcceptsKnownModes(t *testing.T) {
+	for _, s := range []string{"", "tui", "plain", "json"} {
+		mode, err := ParseMode(s)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", s, err)
+		}
+		if string(mode) != s {
+			t.Errorf("expected mode %q, got %q", s, mode)
+		}
+	}
+}
+
+func TestParseMode_RejectsUnknownMode(t *testing.T) {
+	if _, err := ParseMode("fancy"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestResolve_KeepsExplicitMode(t *testing.T) {
+	if got := ModeJSON.Resolve(); got != ModeJSON {
+		t.Errorf("expected %q, got %q", ModeJSON, got)
+	}
+}
+
+func TestJSONRenderer_EmitsOneLinePerTransition(t *testing.T) {
+	var buf bytes.Buffer
+	renderer := &jsonRenderer{out: &buf}
+
+	events := make(chan Event, 10)
+	events <- Event{Kind: KindPlugin, Name: "luckperms", Status: StatusWaiting}
+	events <- Event{Kind: KindPlugin, Name: "luckperms", Status: StatusDownloading, Downloaded: 10, Total: 100}
+	events <- Event{Kind: KindPlugin, Name: "luckperms", Status: StatusDownloading, Downloaded: 50, Total: 100}
+	events <- Event{Kind: KindPlugin, Name: "chunky", Status: StatusWaiting}
+	events <- Event{Kind: KindPlugin, Name: "luckperms", Status: StatusInstalled}
+	close(events)
+
+	if err := renderer.Run(events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d:\n%s", len(lines), buf.String())
+	}
+
+	var last Event
+	if err := json.Unmarshal([]byte(lines[3]), &last); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if last.Name != "luckperms" || last.Status != StatusInstalled {
+		t.Errorf("unexpected last event: %+v", last)
+	}
+}
+
+func TestModel_ProgressDoesNotOverrideFinalState(t *testing.T) {
+	m := model{}
+	updated, _ := m.Update(eventMsg{Kind: KindPlugin, Name: "a", Status: StatusInstalled})
+	updated, _ = updated.(model).Update(eventMsg{Kind: KindPlugin, Name: "a", Status: StatusDownloading, Downloaded: 1, Total: 2})
+
+	rows := updated.(model).rows
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	if rows[0].status != StatusInstalled {
+		t.Errorf("expected status %q, got %q", StatusInstalled, rows[0].status)
+	}
+}
@@ -0,0 +1,37 @@
+package output
+
+import "github.com/charmbracelet/log"
+
+// plainRenderer writes one structured log line per state transition.
+type plainRenderer struct {
+	transitions
+}
+
+func (r *plainRenderer) Run(events <-chan Event) error {
+	for e := range events {
+		if !r.changed(e) {
+			continue
+		}
+
+		keyvals := []interface{}{"name", e.Name, "status", e.Status}
+		if e.Server != "" {
+			keyvals = append([]interface{}{"server", e.Server}, keyvals...)
+		}
+		if e.Source != "" {
+			keyvals = append(keyvals, "source", e.Source)
+		}
+		if e.Status == StatusDownloading && e.Total > 0 {
+			keyvals = append(keyvals, "size", formatSize(e.Total))
+		}
+
+		switch e.Status {
+		case StatusFailed:
+			log.Error(string(e.Kind), append(keyvals, "err", e.Error)...)
+		case StatusWaiting, StatusPreparing:
+			log.Debug(string(e.Kind), keyvals...)
+		default:
+			log.Info(string(e.Kind), keyvals...)
+		}
+	}
+	return nil
+}
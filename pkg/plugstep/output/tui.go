@@ -0,0 +1,221 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// tuiRenderer draws a live Bubble Tea view with one line per item.
+type tuiRenderer struct{}
+
+type model struct {
+	rows []row
+	done bool
+}
+
+type row struct {
+	kind       Kind
+	name       string
+	source     string
+	status     Status
+	downloaded int64
+	total      int64
+}
+
+type eventMsg Event
+type doneMsg struct{}
+
+func (r *tuiRenderer) Run(events <-chan Event) error {
+	p := tea.NewProgram(model{})
+
+	go func() {
+		// Send is a no-op once the program quit (e.g. on ctrl+c), so the
+		// stream keeps draining and the install never blocks on the UI.
+		for e := range events {
+			p.Send(eventMsg(e))
+		}
+		p.Send(doneMsg{})
+	}()
+
+	_, err := p.Run()
+	if err != nil {
+		return fmt.Errorf("UI error: %w", err)
+	}
+	return nil
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case eventMsg:
+		i := m.find(msg.Kind, msg.Name)
+		if i < 0 {
+			m.rows = append(m.rows, row{kind: msg.Kind, name: msg.Name, source: msg.Source})
+			i = len(m.rows) - 1
+		}
+		r := &m.rows[i]
+		if msg.Status == StatusDownloading {
+			// Late progress ticks must not override a final state
+			if r.status == StatusInstalled || r.status == StatusChecked || r.status == StatusFailed {
+				return m, nil
+			}
+			r.downloaded = msg.Downloaded
+			r.total = msg.Total
+		}
+		r.status = msg.Status
+		return m, nil
+
+	case doneMsg:
+		m.done = true
+		return m, tea.Quit
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m model) find(kind Kind, name string) int {
+	for i := range m.rows {
+		if m.rows[i].kind == kind && m.rows[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m model) View() string {
+	var b strings.Builder
+
+	for _, r := range m.rows {
+		if r.kind == KindServer {
+			b.WriteString(renderServerLine(r))
+		} else {
+			b.WriteString(renderPluginLine(r))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func statusBackground(status Status) string {
+	switch status {
+	case StatusFailed:
+		return "#f38ba8"
+	case StatusChecked:
+		return "#89dceb"
+	case StatusInstalled:
+		return "#a6e3a1"
+	case StatusDownloading:
+		return "#89b4fa"
+	}
+	return "#f9e2af"
+}
+
+func renderServerLine(r row) string {
+	badge := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#cdd6f4")).
+		PaddingRight(1).
+		Bold(true).
+		PaddingLeft(1).
+		Render("~")
+
+	statusBadge := lipgloss.NewStyle().
+		Background(lipgloss.Color(statusBackground(r.status))).
+		Foreground(lipgloss.Color("#232634")).
+		PaddingRight(2).
+		PaddingLeft(2).
+		Transform(strings.ToUpper).
+		Render(string(r.status))
+
+	line := fmt.Sprintf("%s%s %s ", badge, statusBadge, r.name)
+
+	if r.status == StatusDownloading {
+		line += renderProgressBar(r.downloaded, r.total, 20)
+	}
+
+	return line
+}
+
+func renderPluginLine(r row) string {
+	badge := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#cdd6f4")).
+		PaddingRight(1).
+		Bold(true).
+		PaddingLeft(1).
+		Render("~")
+
+	sourceBadge := lipgloss.NewStyle().
+		Background(lipgloss.Color("#89b4fa")).
+		Foreground(lipgloss.Color("#11111b")).
+		Width(16).
+		PaddingLeft(1).
+		Transform(strings.ToUpper).
+		Render(r.source)
+
+	statusBadge := lipgloss.NewStyle().
+		Background(lipgloss.Color(statusBackground(r.status))).
+		Foreground(lipgloss.Color("#232634")).
+		Width(15).
+		PaddingLeft(1).
+		Transform(strings.ToUpper).
+		Render(string(r.status))
+
+	nameBadge := lipgloss.NewStyle().
+		Width(30).
+		Render(r.name)
+
+	line := fmt.Sprintf("%s%s%s %s", badge, sourceBadge, statusBadge, nameBadge)
+
+	if r.status == StatusDownloading {
+		line += " " + renderProgressBar(r.downloaded, r.total, 20)
+	}
+
+	return line
+}
+
+func renderProgressBar(downloaded, total int64, width int) string {
+	var percent float64
+	if total > 0 {
+		percent = float64(downloaded) / float64(total)
+	}
+
+	filled := int(percent * float64(width))
+	if filled > width {
+		filled = width
+	}
+
+	filledStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#a6e3a1"))
+	emptyStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#45475a"))
+
+	bar := filledStyle.Render(strings.Repeat("—", filled)) +
+		emptyStyle.Render(strings.Repeat("—", width-filled))
+
+	return fmt.Sprintf("[%s] %s/%s", bar, formatSize(downloaded), formatSize(total))
+}
+
+func formatSize(bytes int64) string {
+	const (
+		KB = 1024
+		MB = KB * 1024
+	)
+	switch {
+	case bytes >= MB:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/float64(MB))
+	case bytes >= KB:
+		return fmt.Sprintf("%.0f KB", float64(bytes)/float64(KB))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
@@ -1,11 +1,17 @@
 package plugstep
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/charmbracelet/log"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/backup"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/bundle"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/output"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/setup"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 )
@@ -14,6 +20,25 @@ type Plugstep struct {
 	Args            []string
 	ServerDirectory string
 	Config          *config.PlugstepConfig
+	Options         Options
+	// Backup receives files before an install replaces or removes them. It
+	// may be nil, in which case nothing is backed up.
+	Backup *backup.Snapshot
+}
+
+// Options tweak how commands operating on the server directory behave.
+type Options struct {
+	// PruneUnmanaged lets cleanup remove files Plugstep didn't install itself.
+	PruneUnmanaged bool
+	// Output selects how install progress is rendered, empty meaning
+	// auto-detect based on whether stdout is a terminal.
+	Output output.Mode
+	// Member names the server in output when it is installed as part of a
+	// workspace.
+	Member string
+	// Bundle, when set, provides the server jar and plugins instead of their
+	// sources, see plugstep vendor.
+	Bundle *bundle.Bundle
 }
 
 func (p *Plugstep) Init() error {
@@ -25,15 +50,21 @@ func (p *Plugstep) Init() error {
 	configPath := filepath.Join(p.ServerDirectory, "plugstep.toml")
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if !setup.IsInteractive() {
+			return exitcode.Errorf(exitcode.Config, "plugstep.toml not found in %s, create one with `plugstep init --project paper --mc <version>`", p.ServerDirectory)
+		}
 		if err := p.runSetupWizard(configPath); err != nil {
 			return err
 		}
 	}
 
-	c, err := config.LoadPlugstepConfig(configPath)
+	c, err := config.Load(configPath)
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		return exitcode.Wrap(exitcode.Config, err)
+	}
 	if err != nil {
-		log.Error("Failed to load Plugstep config", "err", err)
-		return err
+		return exitcode.Wrap(exitcode.Config, fmt.Errorf("failed to load Plugstep config: %w", err))
 	}
 	p.Config = c
 	return nil
@@ -45,19 +76,36 @@ func (p *Plugstep) runSetupWizard(configPath string) error {
 	wizard := setup.NewSetupWizard()
 	result, err := wizard.Run()
 	if err != nil {
-		log.Error("Setup wizard failed", "err", err)
-		return err
+		return exitcode.Wrap(exitcode.Config, fmt.Errorf("setup wizard failed: %w", err))
 	}
 
 	if err := setup.WriteConfig(configPath, result); err != nil {
-		log.Error("Failed to write config", "err", err)
-		return err
+		return fmt.Errorf("failed to write config: %w", err)
 	}
 
 	log.Info("Created plugstep.toml successfully!")
 	return nil
 }
 
+// BeginBackup starts a snapshot for the upcoming install when backups are
+// enabled in the config.
+func (p *Plugstep) BeginBackup() {
+	if p.Config != nil && p.Config.Backups.IsEnabled() {
+		p.Backup = backup.Begin(p.ServerDirectory)
+	}
+}
+
+// FinishBackup writes the snapshot started by BeginBackup, if any.
+func (p *Plugstep) FinishBackup() {
+	if p.Backup == nil {
+		return
+	}
+	if err := p.Backup.Finish(p.Config.Backups.KeepCount()); err != nil {
+		log.Error("Failed to save backup", "err", err)
+	}
+	p.Backup = nil
+}
+
 func CreatePlugstep(args []string, serverDirectory string) *Plugstep {
 	return &Plugstep{
 		Args:            args,
@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
 )
 
@@ -57,7 +59,7 @@ func compareVersions(a, b string) int {
 }
 
 type PaperMCClient struct {
-	baseURL string
+	api utils.Endpoint
 }
 
 type Project struct {
@@ -65,12 +67,14 @@ type Project struct {
 	Name string `json:"name"`
 }
 
-func NewPaperMCClient() *PaperMCClient {
-	return &PaperMCClient{baseURL: "https://fill.papermc.io"}
+// NewPaperMCClient returns a client of the Fill API at api, see
+// config.PlugstepConfig.VendorEndpoint.
+func NewPaperMCClient(api utils.Endpoint) *PaperMCClient {
+	return &PaperMCClient{api: api}
 }
 
 func (c *PaperMCClient) GetProjects() ([]Project, error) {
-	r, err := utils.HTTPClient.Get(fmt.Sprintf("%s/v3/projects", c.baseURL))
+	r, err := c.api.Get("/v3/projects")
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +99,7 @@ func (c *PaperMCClient) GetProjects() ([]Project, error) {
 }
 
 func (c *PaperMCClient) GetVersions(project string) ([]string, error) {
-	r, err := utils.HTTPClient.Get(fmt.Sprintf("%s/v3/projects/%s", c.baseURL, project))
+	r, err := c.api.Get(fmt.Sprintf("/v3/projects/%s", project))
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +126,7 @@ func (c *PaperMCClient) GetVersions(project string) ([]string, error) {
 }
 
 func (c *PaperMCClient) GetBuilds(project, version string) ([]string, error) {
-	r, err := utils.HTTPClient.Get(fmt.Sprintf("%s/v3/projects/%s/versions/%s/builds", c.baseURL, project, version))
+	r, err := c.api.Get(fmt.Sprintf("/v3/projects/%s/versions/%s/builds", project, version))
 	if err != nil {
 		return nil, err
 	}
@@ -145,3 +149,51 @@ func (c *PaperMCClient) GetBuilds(project, version string) ([]string, error) {
 
 	return builds, nil
 }
+
+// Validate checks the server settings of result against Fill. An empty
+// Minecraft version resolves to the newest one and an empty build to latest.
+func (c *PaperMCClient) Validate(result *SetupResult) error {
+	if result.Vendor != string(config.ServerJarVendorPaperMC) {
+		return exitcode.Errorf(exitcode.Usage, "unsupported vendor %q (supported: %s)", result.Vendor, config.ServerJarVendorPaperMC)
+	}
+
+	projects, err := c.GetProjects()
+	if err != nil {
+		return fmt.Errorf("failed to fetch projects: %w", err)
+	}
+	ids := make([]string, len(projects))
+	for i, p := range projects {
+		ids[i] = p.ID
+	}
+	if !slices.Contains(ids, result.Project) {
+		return exitcode.Errorf(exitcode.Usage, "unknown project %q (available: %s)", result.Project, strings.Join(ids, ", "))
+	}
+
+	versions, err := c.GetVersions(result.Project)
+	if err != nil {
+		return fmt.Errorf("failed to fetch versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no versions published for %s", result.Project)
+	}
+	if result.MinecraftVersion == "" {
+		result.MinecraftVersion = versions[0]
+	} else if !slices.Contains(versions, result.MinecraftVersion) {
+		return exitcode.Errorf(exitcode.Usage, "unknown version %q for %s (newest: %s)", result.MinecraftVersion, result.Project, versions[0])
+	}
+
+	builds, err := c.GetBuilds(result.Project, result.MinecraftVersion)
+	if err != nil {
+		return fmt.Errorf("failed to fetch builds: %w", err)
+	}
+	if len(builds) == 0 {
+		return exitcode.Errorf(exitcode.Usage, "no builds published for %s %s", result.Project, result.MinecraftVersion)
+	}
+	if result.BuildVersion == "" {
+		result.BuildVersion = "latest"
+	} else if result.BuildVersion != "latest" && !slices.Contains(builds, result.BuildVersion) {
+		return exitcode.Errorf(exitcode.Usage, "unknown build %q for %s %s (latest: %s)", result.BuildVersion, result.Project, result.MinecraftVersion, builds[0])
+	}
+
+	return nil
+}
@@ -2,9 +2,13 @@ package setup
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/log"
+	"github.com/mattn/go-isatty"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
 )
 
 type SetupResult struct {
@@ -20,10 +24,20 @@ type SetupWizard struct {
 
 func NewSetupWizard() *SetupWizard {
 	return &SetupWizard{
-		papermc: NewPaperMCClient(),
+		papermc: NewPaperMCClient(config.DefaultEndpoint(string(config.ServerJarVendorPaperMC))),
 	}
 }
 
+// IsInteractive reports whether stdin and stdout are terminals, i.e. whether
+// the setup wizard can prompt the user.
+func IsInteractive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
 func (w *SetupWizard) Run() (*SetupResult, error) {
 	result := &SetupResult{}
 
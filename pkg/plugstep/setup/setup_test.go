@@ -0,0 +1,84 @@
+package setup
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/utils"
+)
+
+func newFillServer(t *testing.T) *PaperMCClient {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/projects", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"projects":[{"project":{"id":"paper","name":"Paper"}},{"project":{"id":"velocity","name":"Velocity"}}]}`))
+	})
+	mux.HandleFunc("/v3/projects/paper", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"versions":{"1.21":["1.21.8","1.21.7"],"1.20":["1.20.6"]}}`))
+	})
+	mux.HandleFunc("/v3/projects/paper/versions/1.21.8/builds", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"builds":[{"build":40},{"build":41}]}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return &PaperMCClient{api: utils.Endpoint{URL: server.URL}}
+}
+
+func TestValidate_ResolvesNewestVersion(t *testing.T) {
+	client := newFillServer(t)
+	result := &SetupResult{Vendor: "papermc", Project: "paper"}
+
+	if err := client.Validate(result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.MinecraftVersion != "1.21.8" {
+		t.Errorf("expected newest version 1.21.8, got %s", result.MinecraftVersion)
+	}
+	if result.BuildVersion != "latest" {
+		t.Errorf("expected build latest, got %s", result.BuildVersion)
+	}
+}
+
+func TestValidate_AcceptsPublishedBuild(t *testing.T) {
+	client := newFillServer(t)
+	result := &SetupResult{Vendor: "papermc", Project: "paper", MinecraftVersion: "1.21.8", BuildVersion: "40"}
+
+	if err := client.Validate(result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidate_RejectsUnknownValues(t *testing.T) {
+	client := newFillServer(t)
+	cases := map[string]*SetupResult{
+		"vendor":  {Vendor: "spigot", Project: "paper"},
+		"project": {Vendor: "papermc", Project: "bukkit"},
+		"version": {Vendor: "papermc", Project: "paper", MinecraftVersion: "1.8.8"},
+		"build":   {Vendor: "papermc", Project: "paper", MinecraftVersion: "1.21.8", BuildVersion: "7"},
+	}
+
+	for name, result := range cases {
+		err := client.Validate(result)
+		if code := exitcode.Of(err); code != exitcode.Usage {
+			t.Errorf("%s: expected usage error, got %d (%v)", name, code, err)
+		}
+	}
+}
+
+func TestTemplateNames_Sorted(t *testing.T) {
+	names := TemplateNames()
+
+	if len(names) != len(Templates) {
+		t.Fatalf("expected %d names, got %v", len(Templates), names)
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Errorf("names not sorted: %v", names)
+		}
+	}
+	if _, ok := Templates[DefaultTemplate]; !ok {
+		t.Errorf("default template %q missing", DefaultTemplate)
+	}
+}
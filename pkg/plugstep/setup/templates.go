@@ -0,0 +1,40 @@
+package setup
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Template pre-fills plugstep init for a kind of server.
+type Template struct {
+	Description string
+	Project     string
+}
+
+const DefaultTemplate = "paper"
+
+var Templates = map[string]Template{
+	"paper": {Description: "Paper game server", Project: "paper"},
+	"folia": {Description: "Folia game server with regionised multithreading", Project: "folia"},
+	"proxy": {Description: "Velocity proxy", Project: "velocity"},
+}
+
+// TemplateNames returns the names of all templates, sorted.
+func TemplateNames() []string {
+	names := make([]string, 0, len(Templates))
+	for name := range Templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TemplateHelp lists the templates with their descriptions, one per line.
+func TemplateHelp() string {
+	var b strings.Builder
+	for _, name := range TemplateNames() {
+		fmt.Fprintf(&b, "  %-8s%s\n", name, Templates[name].Description)
+	}
+	return b.String()
+}
@@ -1,29 +1,19 @@
 package setup
 
 import (
-	"os"
-
-	"github.com/BurntSushi/toml"
 	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/config"
 )
 
 func WriteConfig(path string, result *SetupResult) error {
-	cfg := config.PlugstepConfig{
-		Server: config.ServerConfig{
-			Vendor:           config.ServerJarVendor(result.Vendor),
-			Project:          result.Project,
-			MinecraftVersion: result.MinecraftVersion,
-			Version:          result.BuildVersion,
-		},
-		Plugins: []config.PluginConfig{},
-	}
-
-	f, err := os.Create(path)
+	d := config.NewDocument()
+	err := d.AppendTable("server", false, []config.KeyValue{
+		{Key: "vendor", Value: result.Vendor},
+		{Key: "project", Value: result.Project},
+		{Key: "minecraft_version", Value: result.MinecraftVersion},
+		{Key: "version", Value: result.BuildVersion},
+	})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	encoder := toml.NewEncoder(f)
-	return encoder.Encode(cfg)
+	return d.Save(path)
 }
@@ -3,10 +3,13 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -14,7 +17,7 @@ import (
 )
 
 const (
-	schemaVersion   = 1
+	schemaVersion   = 2
 	CacheTTL        = 15 * time.Minute
 	CacheTTLShort   = 15 * time.Minute
 	CacheTTLForever = 0
@@ -24,6 +27,9 @@ type Cache struct {
 	db        *sql.DB
 	namespace string
 	mu        sync.RWMutex
+	// hits and misses count the lookups until CloseCache saves them.
+	hits   atomic.Int64
+	misses atomic.Int64
 }
 
 var (
@@ -40,13 +46,11 @@ func initDB(serverDirectory string) (*sql.DB, error) {
 		return globalDB, nil
 	}
 
-	cacheDir := filepath.Join(serverDirectory, ".plugstep")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+	dbPath := CachePath(serverDirectory)
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		return nil, err
 	}
 
-	dbPath := filepath.Join(cacheDir, "cache.db")
-
 	// Check schema version of existing db
 	if _, err := os.Stat(dbPath); err == nil {
 		db, err := sql.Open("sqlite", dbPath)
@@ -86,9 +90,16 @@ func initDB(serverDirectory string) (*sql.DB, error) {
 			value TEXT NOT NULL,
 			timestamp INTEGER NOT NULL,
 			ttl INTEGER NOT NULL,
+			etag TEXT NOT NULL DEFAULT '',
+			last_modified TEXT NOT NULL DEFAULT '',
 			PRIMARY KEY (namespace, key)
 		);
 		CREATE INDEX IF NOT EXISTS idx_cache_namespace ON cache(namespace);
+		CREATE TABLE IF NOT EXISTS stats (
+			namespace TEXT PRIMARY KEY,
+			hits INTEGER NOT NULL,
+			misses INTEGER NOT NULL
+		);
 	`)
 	if err != nil {
 		db.Close()
@@ -106,17 +117,25 @@ func initDB(serverDirectory string) (*sql.DB, error) {
 	return db, nil
 }
 
+// CachePath returns the location of the cache database of a server
+// directory.
+func CachePath(serverDirectory string) string {
+	return filepath.Join(serverDirectory, ".plugstep", "cache.db")
+}
+
 // InitCacheDB initializes the cache database for a server directory
 func InitCacheDB(serverDirectory string) error {
 	_, err := initDB(serverDirectory)
 	return err
 }
 
-// CloseCache closes the global database connection
+// CloseCache saves the hits and misses counted so far and closes the global
+// database connection
 func CloseCache() {
 	globalDBMu.Lock()
 	defer globalDBMu.Unlock()
 	if globalDB != nil {
+		saveStats()
 		globalDB.Close()
 		globalDB = nil
 	}
@@ -124,6 +143,9 @@ func CloseCache() {
 }
 
 func InitCache(name string) *Cache {
+	globalDBMu.Lock()
+	defer globalDBMu.Unlock()
+
 	if cache, ok := caches[name]; ok {
 		return cache
 	}
@@ -142,6 +164,9 @@ func InitCache(name string) *Cache {
 }
 
 func GetCache(name string) *Cache {
+	globalDBMu.Lock()
+	defer globalDBMu.Unlock()
+
 	return caches[name]
 }
 
@@ -155,7 +180,9 @@ func FlushCache(name string) error {
 		return err
 	}
 
+	globalDBMu.Lock()
 	delete(caches, name)
+	globalDBMu.Unlock()
 	log.Info("Cache flushed", "namespace", name)
 	return nil
 }
@@ -165,40 +192,85 @@ func (c *Cache) Get(key string, dest interface{}) bool {
 		return false
 	}
 
+	found := c.get(key, dest)
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return found
+}
+
+func (c *Cache) get(key string, dest interface{}) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	var value string
+	row, ok := c.row(key)
+	// Expired values are kept until the next Set, offline they are all
+	// there is.
+	if !ok || (!row.fresh() && !Offline) {
+		return false
+	}
+
+	if err := json.Unmarshal([]byte(row.value), dest); err != nil {
+		return false
+	}
+
+	log.Debug("Cache hit", "namespace", c.namespace, "key", key)
+	return true
+}
+
+// cacheRow is a row of the cache table.
+type cacheRow struct {
+	value      string
+	stored     time.Time
+	ttl        time.Duration
+	validators Validators
+}
+
+// fresh reports whether the row is within its TTL (0 = forever).
+func (r cacheRow) fresh() bool {
+	return r.ttl <= 0 || time.Now().Before(r.stored.Add(r.ttl))
+}
+
+func (c *Cache) row(key string) (cacheRow, bool) {
+	var row cacheRow
 	var timestamp, ttl int64
 	err := c.db.QueryRow(
-		"SELECT value, timestamp, ttl FROM cache WHERE namespace = ? AND key = ?",
+		"SELECT value, timestamp, ttl, etag, last_modified FROM cache WHERE namespace = ? AND key = ?",
 		c.namespace, key,
-	).Scan(&value, &timestamp, &ttl)
-
+	).Scan(&row.value, &timestamp, &ttl, &row.validators.ETag, &row.validators.LastModified)
 	if err != nil {
-		return false
+		return row, false
 	}
+	row.stored = time.Unix(0, timestamp)
+	row.ttl = time.Duration(ttl)
+	return row, true
+}
 
-	// Check TTL (0 = forever)
-	if ttl > 0 {
-		expiry := time.Unix(0, timestamp).Add(time.Duration(ttl))
-		if time.Now().After(expiry) {
-			// Expired, delete it
-			go func() {
-				c.mu.Lock()
-				defer c.mu.Unlock()
-				c.db.Exec("DELETE FROM cache WHERE namespace = ? AND key = ?", c.namespace, key)
-			}()
-			return false
-		}
+// Lookup reads the value of key into dest even when it has expired, so that
+// it can be revalidated with its validators. fresh reports whether it is
+// still within its TTL, or can't be revalidated because Plugstep is offline.
+func (c *Cache) Lookup(key string, dest interface{}) (found bool, fresh bool, validators Validators) {
+	if c == nil || c.db == nil {
+		return false, false, Validators{}
 	}
 
-	if err := json.Unmarshal([]byte(value), dest); err != nil {
-		return false
+	c.mu.RLock()
+	row, ok := c.row(key)
+	c.mu.RUnlock()
+	if ok && json.Unmarshal([]byte(row.value), dest) == nil {
+		found = true
+		fresh = row.fresh() || Offline
 	}
 
-	log.Debug("Cache hit", "namespace", c.namespace, "key", key)
-	return true
+	if fresh {
+		c.hits.Add(1)
+		log.Debug("Cache hit", "namespace", c.namespace, "key", key)
+	} else {
+		c.misses.Add(1)
+	}
+	return found, fresh, row.validators
 }
 
 // Set caches a value with the default short TTL
@@ -213,6 +285,12 @@ func (c *Cache) SetPermanent(key string, value interface{}) {
 
 // SetWithTTL caches a value with a specific TTL (0 = forever)
 func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	c.SetValidated(key, value, ttl, Validators{})
+}
+
+// SetValidated caches a value with a specific TTL and the validators of the
+// response it was read from.
+func (c *Cache) SetValidated(key string, value interface{}, ttl time.Duration, validators Validators) {
 	if c == nil || c.db == nil {
 		return
 	}
@@ -226,11 +304,257 @@ func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
 	defer c.mu.Unlock()
 
 	_, err = c.db.Exec(`
-		INSERT OR REPLACE INTO cache (namespace, key, value, timestamp, ttl)
-		VALUES (?, ?, ?, ?, ?)
-	`, c.namespace, key, string(data), time.Now().UnixNano(), int64(ttl))
+		INSERT OR REPLACE INTO cache (namespace, key, value, timestamp, ttl, etag, last_modified)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, c.namespace, key, string(data), time.Now().UnixNano(), int64(ttl), validators.ETag, validators.LastModified)
 
 	if err != nil {
 		log.Debug("Failed to write cache", "err", err)
 	}
 }
+
+// Extend keeps the value of key for another ttl, once a revalidation
+// confirmed it hasn't changed.
+func (c *Cache) Extend(key string, ttl time.Duration) {
+	if c == nil || c.db == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.db.Exec(
+		"UPDATE cache SET timestamp = ?, ttl = ? WHERE namespace = ? AND key = ?",
+		time.Now().UnixNano(), int64(ttl), c.namespace, key,
+	)
+	if err != nil {
+		log.Debug("Failed to write cache", "err", err)
+	}
+}
+
+var errNoCacheDB = errors.New("cache database not initialized")
+
+// saveStats adds the hits and misses counted since the last save to the stats
+// table. Callers hold globalDBMu.
+func saveStats() {
+	for name, c := range caches {
+		hits, misses := c.hits.Swap(0), c.misses.Swap(0)
+		if hits == 0 && misses == 0 {
+			continue
+		}
+		_, err := globalDB.Exec(`
+			INSERT INTO stats (namespace, hits, misses) VALUES (?, ?, ?)
+			ON CONFLICT (namespace) DO UPDATE SET hits = hits + excluded.hits, misses = misses + excluded.misses
+		`, name, hits, misses)
+		if err != nil {
+			log.Debug("Failed to save cache stats", "namespace", name, "err", err)
+		}
+	}
+}
+
+// NamespaceStats summarizes the entries of a cache namespace.
+type NamespaceStats struct {
+	Namespace string
+	Entries   int
+	Expired   int
+	// Size is the size of the keys and values in bytes.
+	Size   int64
+	Hits   int64
+	Misses int64
+}
+
+// HitRatio returns the share of lookups that found a value, 0 without any
+// lookups.
+func (s NamespaceStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CacheStats summarizes every namespace, counting hits and misses since the
+// namespace was last cleared.
+func CacheStats() ([]NamespaceStats, error) {
+	globalDBMu.Lock()
+	defer globalDBMu.Unlock()
+	if globalDB == nil {
+		return nil, errNoCacheDB
+	}
+	saveStats()
+
+	byName := map[string]*NamespaceStats{}
+	namespace := func(name string) *NamespaceStats {
+		if _, ok := byName[name]; !ok {
+			byName[name] = &NamespaceStats{Namespace: name}
+		}
+		return byName[name]
+	}
+
+	rows, err := globalDB.Query(`
+		SELECT namespace, COUNT(*), SUM(LENGTH(CAST(key AS BLOB)) + LENGTH(CAST(value AS BLOB))),
+			SUM(CASE WHEN ttl > 0 AND timestamp + ttl < ? THEN 1 ELSE 0 END)
+		FROM cache GROUP BY namespace
+	`, time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var s NamespaceStats
+		if err := rows.Scan(&name, &s.Entries, &s.Size, &s.Expired); err != nil {
+			return nil, err
+		}
+		ns := namespace(name)
+		ns.Entries, ns.Size, ns.Expired = s.Entries, s.Size, s.Expired
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = globalDB.Query("SELECT namespace, hits, misses FROM stats")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var hits, misses int64
+		if err := rows.Scan(&name, &hits, &misses); err != nil {
+			return nil, err
+		}
+		ns := namespace(name)
+		ns.Hits, ns.Misses = hits, misses
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := make([]NamespaceStats, 0, len(byName))
+	for _, s := range byName {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Namespace < stats[j].Namespace })
+	return stats, nil
+}
+
+// CacheEntry is a value in the cache.
+type CacheEntry struct {
+	Namespace string
+	Key       string
+	// Size is the size of the key and value in bytes.
+	Size   int64
+	Stored time.Time
+	// TTL is how long the value is fresh, 0 meaning forever.
+	TTL time.Duration
+	// Validators are those of the response the value was read from.
+	Validators Validators
+}
+
+// Expired reports whether the entry is past its TTL. Offline installs still
+// use it, and CachedGet revalidates it when it has validators.
+func (e CacheEntry) Expired() bool {
+	return e.TTL > 0 && time.Since(e.Stored) > e.TTL
+}
+
+// Revalidatable reports whether the entry can be revalidated with a
+// conditional request once it has expired, rather than fetched again.
+func (e CacheEntry) Revalidatable() bool {
+	return e.Validators.ETag != "" || e.Validators.LastModified != ""
+}
+
+// CacheEntries lists the entries of namespace, or of every namespace when
+// empty, sorted by namespace and key.
+func CacheEntries(namespace string) ([]CacheEntry, error) {
+	globalDBMu.Lock()
+	db := globalDB
+	globalDBMu.Unlock()
+	if db == nil {
+		return nil, errNoCacheDB
+	}
+
+	rows, err := db.Query(`
+		SELECT namespace, key, LENGTH(CAST(key AS BLOB)) + LENGTH(CAST(value AS BLOB)), timestamp, ttl, etag, last_modified
+		FROM cache WHERE ? = '' OR namespace = ? ORDER BY namespace, key
+	`, namespace, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []CacheEntry
+	for rows.Next() {
+		var e CacheEntry
+		var timestamp, ttl int64
+		if err := rows.Scan(&e.Namespace, &e.Key, &e.Size, &timestamp, &ttl, &e.Validators.ETag, &e.Validators.LastModified); err != nil {
+			return nil, err
+		}
+		e.Stored = time.Unix(0, timestamp)
+		e.TTL = time.Duration(ttl)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// PruneCache deletes the expired entries and the hashes of files that were
+// deleted or changed since they were hashed. It returns how many of each it
+// deleted. Expired entries with validators are kept, revalidating them is
+// cheaper than fetching them again.
+func PruneCache() (expired int, stale int, err error) {
+	entries, err := CacheEntries("")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, e := range entries {
+		switch {
+		case e.Expired() && !e.Revalidatable():
+			expired++
+		case e.Namespace == fileHashCacheName && staleFileHash(e.Key):
+			stale++
+		default:
+			continue
+		}
+		if err := deleteCacheEntry(e.Namespace, e.Key); err != nil {
+			return expired, stale, err
+		}
+	}
+	return expired, stale, nil
+}
+
+func deleteCacheEntry(namespace, key string) error {
+	globalDBMu.Lock()
+	defer globalDBMu.Unlock()
+	if globalDB == nil {
+		return errNoCacheDB
+	}
+	_, err := globalDB.Exec("DELETE FROM cache WHERE namespace = ? AND key = ?", namespace, key)
+	return err
+}
+
+// ClearCache deletes every entry and the hit statistics of namespace, or of
+// every namespace when empty, returning how many entries it deleted.
+func ClearCache(namespace string) (int, error) {
+	globalDBMu.Lock()
+	defer globalDBMu.Unlock()
+	if globalDB == nil {
+		return 0, errNoCacheDB
+	}
+
+	result, err := globalDB.Exec("DELETE FROM cache WHERE ? = '' OR namespace = ?", namespace, namespace)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := globalDB.Exec("DELETE FROM stats WHERE ? = '' OR namespace = ?", namespace, namespace); err != nil {
+		return 0, err
+	}
+	for name, c := range caches {
+		if namespace == "" || name == namespace {
+			c.hits.Store(0)
+			c.misses.Store(0)
+		}
+	}
+
+	cleared, err := result.RowsAffected()
+	return int(cleared), err
+}
@@ -6,6 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 )
 
 const fileHashCacheName = "filehash"
@@ -40,6 +45,29 @@ func CalculateFileSHA512(filename string) (string, error) {
 	})
 }
 
+// VerifyFileChecksum checks filename against the expected sha256 or sha512
+// checksum, returning an error tagged exitcode.Checksum on mismatch.
+func VerifyFileChecksum(filename, checksumType, expected string) error {
+	var hash string
+	var err error
+	switch checksumType {
+	case "sha256":
+		hash, err = CalculateFileSHA256(filename)
+	case "sha512":
+		hash, err = CalculateFileSHA512(filename)
+	default:
+		return fmt.Errorf("unsupported checksum type: %s", checksumType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if hash != expected {
+		return exitcode.Errorf(exitcode.Checksum, "checksum mismatch for %s: expected %s, got %s", filepath.Base(filename), expected, hash)
+	}
+	return nil
+}
+
 func calculateFileHash(filename string, hashType string, hashFunc func(*os.File) (string, error)) (string, error) {
 	stat, err := os.Stat(filename)
 	if err != nil {
@@ -82,3 +110,21 @@ func calculateFileHash(filename string, hashType string, hashFunc func(*os.File)
 
 	return hash, nil
 }
+
+// staleFileHash reports whether the file of a file hash cache key was deleted
+// or changed since it was hashed, so that the hash can never be used again.
+func staleFileHash(key string) bool {
+	_, rest, _ := strings.Cut(key, ":")
+	i := strings.LastIndex(rest, ":")
+	j := strings.LastIndex(rest[:max(i, 0)], ":")
+	if i < 0 || j < 0 {
+		return true
+	}
+	filename, size, mtime := rest[:j], rest[j+1:i], rest[i+1:]
+
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return strconv.FormatInt(stat.Size(), 10) != size || strconv.FormatInt(stat.ModTime().UnixNano(), 10) != mtime
+}
@@ -1,10 +1,11 @@
 package utils
 
 import (
-	"fmt"
 	"io"
 	"net/http"
 	"os"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 )
 
 func DownloadFile(url, destPath string) error {
@@ -21,9 +22,14 @@ func DownloadFileWithProgress(url, destPath string, onProgress ProgressFunc) err
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+		return exitcode.Errorf(exitcode.Network, "bad status: %s", resp.Status)
 	}
 
+	// Replace rather than truncate the file, it may be a hard link into the
+	// download store.
+	if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	out, err := os.Create(destPath)
 	if err != nil {
 		return err
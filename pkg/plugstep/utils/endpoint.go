@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// Endpoint is the URL of an API and the mirrors tried before it, e.g. an
+// internal caching proxy.
+type Endpoint struct {
+	URL     string
+	Mirrors []string
+}
+
+// URLs returns the mirrors and then the URL, in the order they are tried,
+// without trailing slashes.
+func (e Endpoint) URLs() []string {
+	var urls []string
+	for _, u := range append(append([]string{}, e.Mirrors...), e.URL) {
+		if u = strings.TrimSuffix(u, "/"); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Get requests path below each URL of the endpoint in turn. It moves on to
+// the next URL after a network error or a 404, 429 or 5xx response, and
+// returns the response of the last one it tried.
+func (e Endpoint) Get(path string) (*http.Response, error) {
+	return e.GetWithHeader(path, nil)
+}
+
+// GetWithHeader is Get with additional request headers, e.g. to revalidate a
+// cached response.
+func (e Endpoint) GetWithHeader(path string, header http.Header) (*http.Response, error) {
+	urls := e.URLs()
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no URL configured for %s", path)
+	}
+
+	var r *http.Response
+	var err error
+	for i, base := range urls {
+		r, err = getWithHeader(base+path, header)
+		if i == len(urls)-1 || (err == nil && !shouldFallBack(r.StatusCode)) {
+			break
+		}
+		if err != nil {
+			log.Debug("Mirror unreachable, trying the next URL", "url", base, "err", err)
+			continue
+		}
+		r.Body.Close()
+		log.Debug("Mirror failed, trying the next URL", "url", base, "status", r.StatusCode)
+	}
+	return r, err
+}
+
+func getWithHeader(url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return HTTPClient.Do(req)
+}
+
+// shouldFallBack reports whether a mirror's response means the next URL
+// should be tried: it doesn't have the resource, is rate limited or broken.
+func shouldFallBack(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusTooManyRequests || status >= 500
+}
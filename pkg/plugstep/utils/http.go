@@ -1,19 +1,130 @@
 package utils
 
 import (
+	"errors"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+// UserAgent identifies Plugstep to the APIs it calls, as APIs like Modrinth
+// ask clients to. main sets the version.
+var UserAgent = "plugstep/dev (+https://forgejo.perny.dev/mineframe/plugstep)"
+
+// Offline makes every request fail with ErrOffline, so that metadata comes
+// from the cache and files from the download store or the server directory.
+var Offline bool
+
+// ErrOffline is the error of requests made while Offline.
+var ErrOffline = errors.New("not available offline")
+
 // HTTPClient is a shared HTTP client with a 30-second timeout for API calls.
 var HTTPClient = &http.Client{
-	Timeout: 30 * time.Second,
+	Timeout:   30 * time.Second,
+	Transport: &requestTransport{},
 }
 
 // DownloadClient is a shared HTTP client with a 5-minute timeout for file downloads.
 var DownloadClient = &http.Client{
-	Timeout: 5 * time.Minute,
+	Timeout:   5 * time.Minute,
+	Transport: &requestTransport{},
+}
+
+// RequestRule adds headers and credentials to the requests whose URL starts
+// with Prefix, e.g. the API of a plugin source.
+type RequestRule struct {
+	Prefix  string
+	Headers map[string]string
+	// Username and Password are sent as basic auth when Username is set.
+	Username string
+	Password string
+	// Authorize, when set, authenticates a request, e.g. with a session token
+	// it fetches first.
+	Authorize func(req *http.Request) error
+}
+
+var (
+	rulesMu      sync.RWMutex
+	requestRules []RequestRule
+)
+
+// SetRequestRules replaces the rules applied to requests of both clients.
+// The rule with the longest matching prefix applies.
+func SetRequestRules(rules []RequestRule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	requestRules = rules
+}
+
+func matchRequestRule(url string) (RequestRule, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	var match RequestRule
+	found := false
+	for _, rule := range requestRules {
+		if hasURLPrefix(url, rule.Prefix) && (!found || len(rule.Prefix) > len(match.Prefix)) {
+			match = rule
+			found = true
+		}
+	}
+	return match, found
+}
+
+// hasURLPrefix reports whether url is below prefix, so that a prefix of
+// https://repo.example.com doesn't match https://repo.example.com.evil.net.
+func hasURLPrefix(url, prefix string) bool {
+	if prefix == "" || !strings.HasPrefix(url, prefix) {
+		return false
+	}
+	if strings.HasSuffix(prefix, "/") || len(url) == len(prefix) {
+		return true
+	}
+	switch url[len(prefix)] {
+	case '/', '?', '#':
+		return true
+	}
+	return false
+}
+
+// requestTransport sets the User-Agent and applies the request rules. It
+// runs for every hop of a redirect, so credentials never follow a redirect
+// to another host.
+type requestTransport struct {
+	// base sends the requests, the network transport when nil.
+	base http.RoundTripper
+}
+
+func (t *requestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if Offline {
+		return nil, ErrOffline
+	}
+
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent)
+	}
+
+	if rule, ok := matchRequestRule(req.URL.String()); ok {
+		for name, value := range rule.Headers {
+			req.Header.Set(name, value)
+		}
+		if rule.Username != "" {
+			req.SetBasicAuth(rule.Username, rule.Password)
+		}
+		if rule.Authorize != nil {
+			if err := rule.Authorize(req); err != nil {
+				return nil, err
+			}
+		}
+	}
+	base := t.base
+	if base == nil {
+		base = networkTransport()
+	}
+	return base.RoundTrip(req)
 }
 
 // ThrottleBytesPerSecond limits download speed when > 0 (for testing).
@@ -23,7 +134,7 @@ var ThrottleBytesPerSecond int
 func SetThrottledTransport(bytesPerSecond int) {
 	ThrottleBytesPerSecond = bytesPerSecond
 	DownloadClient.Transport = &throttledTransport{
-		base:           http.DefaultTransport,
+		base:           &requestTransport{},
 		bytesPerSecond: bytesPerSecond,
 	}
 }
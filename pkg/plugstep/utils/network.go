@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// NetworkOptions configure how both clients reach the network.
+type NetworkOptions struct {
+	// Proxy replaces HTTP_PROXY and HTTPS_PROXY, e.g. http://proxy:3128 or
+	// socks5://proxy:1080. NO_PROXY still applies.
+	Proxy string
+	// CABundle is a PEM file whose certificates are trusted in addition to
+	// the system roots, e.g. of a TLS intercepting proxy.
+	CABundle string
+}
+
+var (
+	networkMu sync.RWMutex
+	network   http.RoundTripper = http.DefaultTransport
+)
+
+// networkTransport returns the transport every request is sent with.
+func networkTransport() http.RoundTripper {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+	return network
+}
+
+// ConfigureNetwork replaces the transport of both clients, including
+// throttled downloads. Without options, requests use the proxy of the
+// environment and the system roots.
+func ConfigureNetwork(options NetworkOptions) error {
+	transport, err := newNetworkTransport(options)
+	if err != nil {
+		return err
+	}
+
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	network = transport
+	return nil
+}
+
+func newNetworkTransport(options NetworkOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy %q must be an http, https or socks5 URL", Redact(options.Proxy))
+		}
+		if password, ok := proxyURL.User.Password(); ok {
+			RegisterSecret(password)
+		}
+
+		noProxy := os.Getenv("NO_PROXY")
+		if noProxy == "" {
+			noProxy = os.Getenv("no_proxy")
+		}
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL, noProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	if options.CABundle != "" {
+		pem, err := os.ReadFile(options.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", options.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return transport, nil
+}
+
+// bypassProxy reports whether u is reached directly: it is on this machine
+// or matches an entry of noProxy, a NO_PROXY list of hosts, domains, IPs and
+// CIDRs, optionally with a port.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+
+	for _, entry := range strings.FieldsFunc(noProxy, func(r rune) bool { return r == ',' || r == ' ' }) {
+		entry = strings.ToLower(entry)
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = entry, ""
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets in output.
+const redacted = "[redacted]"
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret makes Redact, and so the log output, hide value. Values too
+// short to be credentials are ignored, they would redact unrelated text.
+func RegisterSecret(value string) {
+	if len(value) < 6 {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, secret := range secrets {
+		if secret == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+}
+
+// Redact replaces every registered secret in s.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+// RedactingWriter wraps w so registered secrets never reach it. Secrets split
+// across writes aren't caught, which is fine for the log, writing whole lines.
+func RedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Validators identify the version of a response an API returned, so that it
+// can tell whether a cached copy is still current.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ResponseValidators returns the ETag and Last-Modified headers of r.
+func ResponseValidators(r *http.Response) Validators {
+	return Validators{
+		ETag:         r.Header.Get("ETag"),
+		LastModified: r.Header.Get("Last-Modified"),
+	}
+}
+
+// header returns the conditional request headers for the validators.
+func (v Validators) header() http.Header {
+	header := http.Header{}
+	if v.ETag != "" {
+		header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		header.Set("If-Modified-Since", v.LastModified)
+	}
+	return header
+}
+
+// DecodeFunc reads a response into dest, failing on unexpected statuses.
+type DecodeFunc func(r *http.Response, dest interface{}) error
+
+// CachedGet reads the value of key from c into dest, requesting path from e
+// and decoding the response when it isn't cached or has expired. An expired
+// value is revalidated with the validators it was stored with: when the API
+// answers 304 Not Modified it is kept for another ttl, without downloading
+// it again. c may be nil.
+func CachedGet(c *Cache, key string, ttl time.Duration, e Endpoint, path string, dest interface{}, decode DecodeFunc) error {
+	found, fresh, validators := c.Lookup(key, dest)
+	if fresh {
+		return nil
+	}
+
+	var header http.Header
+	if found {
+		header = validators.header()
+	}
+	r, err := e.GetWithHeader(path, header)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if found && r.StatusCode == http.StatusNotModified {
+		log.Debug("Cache revalidated", "namespace", c.namespace, "key", key)
+		c.Extend(key, ttl)
+		return nil
+	}
+
+	// dest holds the expired value, which mustn't leak into the new one.
+	value := reflect.ValueOf(dest).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := decode(r, dest); err != nil {
+		return err
+	}
+	c.SetValidated(key, dest, ttl, ResponseValidators(r))
+	return nil
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StoreEnv overrides the location of the download store.
+const StoreEnv = "PLUGSTEP_STORE"
+
+// StoreDirectory is where downloads with a known checksum are kept, as
+// <type>/<checksum>, so every server of the user downloads each file only
+// once. Empty disables the store.
+var StoreDirectory string
+
+// DefaultStoreDirectory returns $PLUGSTEP_STORE, or the store in the user's
+// cache directory, e.g. $XDG_CACHE_HOME/plugstep/store. It is empty when
+// there is no cache directory.
+func DefaultStoreDirectory() string {
+	if dir := os.Getenv(StoreEnv); dir != "" {
+		return dir
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "plugstep", "store")
+}
+
+var storeLocks sync.Map
+
+// DownloadVerified downloads url to destPath and checks it against the sha256
+// or sha512 checksum. With a store configured, the file is taken from the
+// store when present, otherwise downloaded into it, and linked into place.
+func DownloadVerified(url, destPath, checksumType, checksum string, onProgress ProgressFunc) error {
+	if Offline && !InStore(checksumType, checksum) {
+		return fmt.Errorf("%s is %w, it isn't in the download store", url, ErrOffline)
+	}
+	if StoreDirectory == "" {
+		if err := DownloadFileWithProgress(url, destPath, onProgress); err != nil {
+			return err
+		}
+		if err := VerifyFileChecksum(destPath, checksumType, checksum); err != nil {
+			os.Remove(destPath)
+			return err
+		}
+		return nil
+	}
+
+	stored, err := storeFile(url, checksumType, checksum, onProgress)
+	if err != nil {
+		return err
+	}
+	return linkFile(stored, destPath)
+}
+
+// InStore reports whether the store has an intact file with the checksum.
+func InStore(checksumType, checksum string) bool {
+	if StoreDirectory == "" {
+		return false
+	}
+	return VerifyFileChecksum(filepath.Join(StoreDirectory, checksumType, checksum), checksumType, checksum) == nil
+}
+
+// StoreDownload downloads url into the store unless it is there already, e.g.
+// to prepare an offline install.
+func StoreDownload(url, checksumType, checksum string, onProgress ProgressFunc) error {
+	if StoreDirectory == "" {
+		return fmt.Errorf("no download store, set $%s", StoreEnv)
+	}
+	_, err := storeFile(url, checksumType, checksum, onProgress)
+	return err
+}
+
+// InstallVerified links or copies the local file src to destPath after
+// checking it against the sha256 or sha512 checksum, e.g. to install from a
+// bundle.
+func InstallVerified(src, destPath, checksumType, checksum string) error {
+	if err := VerifyFileChecksum(src, checksumType, checksum); err != nil {
+		return err
+	}
+	return linkFile(src, destPath)
+}
+
+// storeFile returns the path of the file with the given checksum in the store,
+// downloading it first when the store doesn't have it yet.
+func storeFile(url, checksumType, checksum string, onProgress ProgressFunc) (string, error) {
+	storePath := filepath.Join(StoreDirectory, checksumType, checksum)
+
+	lock, _ := storeLocks.LoadOrStore(storePath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(storePath); err == nil {
+		// A store file linked into a server can be modified in place, so it is
+		// checked before being handed out again.
+		if VerifyFileChecksum(storePath, checksumType, checksum) == nil {
+			// The modification time records the last use for GCStore.
+			now := time.Now()
+			os.Chtimes(storePath, now, now)
+			return storePath, nil
+		}
+		os.Remove(storePath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(storePath), checksum+".*.part")
+	if err != nil {
+		return "", fmt.Errorf("failed to create store: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := DownloadFileWithProgress(url, tmp.Name(), onProgress); err != nil {
+		return "", err
+	}
+	if err := VerifyFileChecksum(tmp.Name(), checksumType, checksum); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), storePath); err != nil {
+		return "", err
+	}
+	return storePath, nil
+}
+
+// linkFile hard links src to dest, copying it when the two can't be linked,
+// e.g. because they are on different filesystems.
+func linkFile(src, dest string) error {
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+// StoreEntry is a file in the download store.
+type StoreEntry struct {
+	Path         string
+	ChecksumType string
+	Checksum     string
+	Size         int64
+	// LastUsed is when the file was last downloaded or installed.
+	LastUsed time.Time
+}
+
+// StoreEntries lists the files in the store, least recently used first.
+func StoreEntries() ([]StoreEntry, error) {
+	if StoreDirectory == "" {
+		return nil, nil
+	}
+
+	var entries []StoreEntry
+	for _, checksumType := range []string{"sha256", "sha512"} {
+		files, err := os.ReadDir(filepath.Join(StoreDirectory, checksumType))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil || !info.Mode().IsRegular() || strings.Contains(file.Name(), ".") {
+				continue
+			}
+			entries = append(entries, StoreEntry{
+				Path:         filepath.Join(StoreDirectory, checksumType, file.Name()),
+				ChecksumType: checksumType,
+				Checksum:     file.Name(),
+				Size:         info.Size(),
+				LastUsed:     info.ModTime(),
+			})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b StoreEntry) int {
+		return a.LastUsed.Compare(b.LastUsed)
+	})
+	return entries, nil
+}
+
+// GCStore removes the store files unused for longer than maxAge, then the
+// least recently used ones until the store is no larger than maxSize. Zero
+// disables either limit. Servers keep the files linked into them.
+func GCStore(maxAge time.Duration, maxSize int64) (removed []StoreEntry, kept []StoreEntry, err error) {
+	entries, err := StoreEntries()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+
+	for _, entry := range entries {
+		expired := maxAge > 0 && time.Since(entry.LastUsed) > maxAge
+		if !expired && (maxSize <= 0 || size <= maxSize) {
+			kept = append(kept, entry)
+			continue
+		}
+		if err := removeStoreFile(entry.Path); err != nil {
+			return removed, kept, err
+		}
+		removed = append(removed, entry)
+		size -= entry.Size
+	}
+	return removed, kept, nil
+}
+
+func removeStoreFile(path string) error {
+	lock, _ := storeLocks.LoadOrStore(path, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -1,10 +1,24 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"forgejo.perny.dev/mineframe/plugstep/pkg/plugstep/exitcode"
 )
 
 // =============================================================================
@@ -211,6 +225,27 @@ func TestCalculateFileSHA256_DifferentFilesDifferentHashes(t *testing.T) {
 	}
 }
 
+func TestVerifyFileChecksum(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "test.jar")
+	if err := os.WriteFile(file, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	expected := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if err := VerifyFileChecksum(file, "sha256", expected); err != nil {
+		t.Errorf("expected matching checksum, got %v", err)
+	}
+
+	err := VerifyFileChecksum(file, "sha256", "deadbeef")
+	if err == nil {
+		t.Fatal("expected mismatch error")
+	}
+	if code := exitcode.Of(err); code != exitcode.Checksum {
+		t.Errorf("expected checksum exit code, got %d", code)
+	}
+}
+
 // =============================================================================
 // Cache Tests
 // =============================================================================
@@ -460,3 +495,576 @@ func TestDownloadClient_Exists(t *testing.T) {
 		t.Errorf("expected 5m timeout, got %v", DownloadClient.Timeout)
 	}
 }
+
+// =============================================================================
+// Store Tests
+// =============================================================================
+
+func storeServer(t *testing.T, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestDownloadVerified_StoreDownloadsOnce(t *testing.T) {
+	tempDir := t.TempDir()
+	StoreDirectory = filepath.Join(tempDir, "store")
+	t.Cleanup(func() { StoreDirectory = "" })
+
+	body := "plugin jar"
+	sum := sha256.Sum256([]byte(body))
+	checksum := hex.EncodeToString(sum[:])
+	server, hits := storeServer(t, body)
+
+	for _, name := range []string{"lobby.jar", "survival.jar"} {
+		dest := filepath.Join(tempDir, name)
+		if err := DownloadVerified(server.URL, dest, "sha256", checksum, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(dest)
+		if err != nil || string(data) != body {
+			t.Errorf("expected %s to contain the download, got %q (%v)", name, data, err)
+		}
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("expected a single download, got %d", hits.Load())
+	}
+	if _, err := os.Stat(filepath.Join(StoreDirectory, "sha256", checksum)); err != nil {
+		t.Errorf("expected the download in the store: %v", err)
+	}
+}
+
+func TestDownloadVerified_ReplacesModifiedStoreFile(t *testing.T) {
+	tempDir := t.TempDir()
+	StoreDirectory = filepath.Join(tempDir, "store")
+	t.Cleanup(func() { StoreDirectory = "" })
+
+	body := "plugin jar"
+	sum := sha256.Sum256([]byte(body))
+	checksum := hex.EncodeToString(sum[:])
+	server, hits := storeServer(t, body)
+
+	stored := filepath.Join(StoreDirectory, "sha256", checksum)
+	os.MkdirAll(filepath.Dir(stored), 0755)
+	os.WriteFile(stored, []byte("modified"), 0644)
+
+	dest := filepath.Join(tempDir, "plugin.jar")
+	if err := DownloadVerified(server.URL, dest, "sha256", checksum, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected the modified store file to be downloaded again, got %d downloads", hits.Load())
+	}
+	if data, _ := os.ReadFile(dest); string(data) != body {
+		t.Errorf("expected the fresh download, got %q", data)
+	}
+}
+
+func TestDownloadVerified_ChecksumMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	server, _ := storeServer(t, "tampered")
+
+	dest := filepath.Join(tempDir, "plugin.jar")
+	err := DownloadVerified(server.URL, dest, "sha256", "0000", nil)
+
+	if code := exitcode.Of(err); code != exitcode.Checksum {
+		t.Errorf("expected checksum exit code, got %d (%v)", code, err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("expected the mismatching download to be removed")
+	}
+}
+
+// =============================================================================
+// Redaction Tests
+// =============================================================================
+
+func TestRedactingWriter_HidesRegisteredSecrets(t *testing.T) {
+	RegisterSecret("tok-123456")
+	RegisterSecret("abc") // too short to redact
+
+	var buf bytes.Buffer
+	w := RedactingWriter(&buf)
+	n, err := w.Write([]byte("GET https://example.com/?token=tok-123456&abc=1\n"))
+
+	if err != nil || n != 48 {
+		t.Fatalf("expected the full write to be reported, got %d (%v)", n, err)
+	}
+	if got := buf.String(); got != "GET https://example.com/?token=[redacted]&abc=1\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+// =============================================================================
+// Request Rule Tests
+// =============================================================================
+
+func TestRequestTransport_AppliesRulesByPrefix(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	SetRequestRules([]RequestRule{
+		{Prefix: server.URL + "/api", Headers: map[string]string{"Authorization": "secret-token"}},
+		{Prefix: server.URL + "/api/private", Username: "ci", Password: "hunter22"},
+	})
+	t.Cleanup(func() { SetRequestRules(nil) })
+
+	cases := []struct {
+		path, authorization string
+	}{
+		{"/api/project", "secret-token"},
+		{"/api/private/file.jar", "Basic Y2k6aHVudGVyMjI="},
+		{"/apix", ""},
+		{"/other", ""},
+	}
+	for _, c := range cases {
+		r, err := HTTPClient.Get(server.URL + c.path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		r.Body.Close()
+
+		if auth := got.Get("Authorization"); auth != c.authorization {
+			t.Errorf("%s: expected Authorization %q, got %q", c.path, c.authorization, auth)
+		}
+		if ua := got.Get("User-Agent"); ua != UserAgent {
+			t.Errorf("%s: expected User-Agent %q, got %q", c.path, UserAgent, ua)
+		}
+	}
+}
+
+func TestRequestTransport_CredentialsDontFollowRedirects(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("Authorization")
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/file.jar", http.StatusFound)
+	}))
+	defer server.Close()
+
+	SetRequestRules([]RequestRule{{Prefix: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}})
+	t.Cleanup(func() { SetRequestRules(nil) })
+
+	r, err := DownloadClient.Get(server.URL + "/file.jar")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.Body.Close()
+
+	if leaked != "" {
+		t.Errorf("expected no credentials on the redirected request, got %q", leaked)
+	}
+}
+
+func TestEndpoint_FallsBackToTheNextURL(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	endpoint := Endpoint{URL: upstream.URL + "/", Mirrors: []string{down.URL, broken.URL}}
+	r, err := endpoint.Get("/v2/project")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Body.Close()
+
+	var body bytes.Buffer
+	body.ReadFrom(r.Body)
+	if r.StatusCode != http.StatusOK || body.String() != "/v2/project" {
+		t.Errorf("expected the upstream response, got %d %q", r.StatusCode, body.String())
+	}
+}
+
+func TestEndpoint_KeepsMirrorClientErrors(t *testing.T) {
+	var upstreamHits atomic.Int32
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer mirror.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+	}))
+	defer upstream.Close()
+
+	r, err := Endpoint{URL: upstream.URL, Mirrors: []string{mirror.URL}}.Get("/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.Body.Close()
+
+	if r.StatusCode != http.StatusBadRequest || upstreamHits.Load() != 0 {
+		t.Errorf("expected the mirror's 400 without asking upstream, got %d and %d upstream requests", r.StatusCode, upstreamHits.Load())
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := "internal.example.com, .corp.net,10.0.0.0/8, repo.example.org:8443"
+	tests := []struct {
+		url    string
+		bypass bool
+	}{
+		{"https://api.modrinth.com/v2", false},
+		{"https://internal.example.com/a", true},
+		{"https://mirror.internal.example.com/a", true},
+		{"https://corp.net/", true},
+		{"https://notcorp.net/", false},
+		{"http://10.1.2.3/", true},
+		{"https://repo.example.org:8443/", true},
+		{"https://repo.example.org/", false},
+		{"http://localhost:8080/", true},
+		{"http://127.0.0.1:8080/", true},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := bypassProxy(u, noProxy); got != tt.bypass {
+			t.Errorf("bypassProxy(%s) = %v, expected %v", tt.url, got, tt.bypass)
+		}
+	}
+}
+
+func TestConfigureNetwork_ExplicitProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "internal.example.com")
+
+	transport, err := newNetworkTransport(NetworkOptions{Proxy: "socks5://proxy.example.com:1080"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.modrinth.com/v2", nil)
+	if proxy, _ := transport.Proxy(req); proxy == nil || proxy.String() != "socks5://proxy.example.com:1080" {
+		t.Errorf("expected the socks5 proxy, got %v", proxy)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "https://internal.example.com/", nil)
+	if proxy, _ := transport.Proxy(req); proxy != nil {
+		t.Errorf("expected NO_PROXY to bypass the proxy, got %v", proxy)
+	}
+
+	if _, err := newNetworkTransport(NetworkOptions{Proxy: "ftp://proxy.example.com"}); err == nil {
+		t.Error("expected an error for an ftp proxy")
+	}
+}
+
+func TestConfigureNetwork_CABundleAppliesToThrottledDownloads(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("jar"))
+	}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certificate, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	SetThrottledTransport(1024 * 1024)
+	t.Cleanup(func() {
+		ThrottleBytesPerSecond = 0
+		DownloadClient.Transport = &requestTransport{}
+		ConfigureNetwork(NetworkOptions{})
+	})
+
+	if _, err := DownloadClient.Get(server.URL); err == nil {
+		t.Fatal("expected the test certificate to be untrusted without the bundle")
+	}
+
+	if err := ConfigureNetwork(NetworkOptions{CABundle: bundle}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := DownloadClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the bundle to be trusted, got %v", err)
+	}
+	r.Body.Close()
+}
+
+func TestGCStore_EvictsByAgeThenSize(t *testing.T) {
+	StoreDirectory = t.TempDir()
+	t.Cleanup(func() { StoreDirectory = "" })
+
+	now := time.Now()
+	files := []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{"sha512/old", 10, 60 * 24 * time.Hour},
+		{"sha256/older-but-small", 5, 10 * 24 * time.Hour},
+		{"sha256/recent", 20, time.Hour},
+		{"sha512/newest", 20, time.Minute},
+	}
+	for _, f := range files {
+		path := filepath.Join(StoreDirectory, filepath.FromSlash(f.name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, bytes.Repeat([]byte("x"), f.size), 0644)
+		os.Chtimes(path, now.Add(-f.age), now.Add(-f.age))
+	}
+	os.WriteFile(filepath.Join(StoreDirectory, "sha256", "partial.123.part"), []byte("x"), 0644)
+
+	removed, kept, err := GCStore(30*24*time.Hour, 40)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, entry := range removed {
+		names = append(names, entry.Checksum)
+	}
+	if expected := []string{"old", "older-but-small"}; !slices.Equal(names, expected) {
+		t.Errorf("expected %v removed, got %v", expected, names)
+	}
+	if len(kept) != 2 {
+		t.Errorf("expected 2 files kept, got %d", len(kept))
+	}
+	if _, err := os.Stat(filepath.Join(StoreDirectory, "sha512", "newest")); err != nil {
+		t.Errorf("expected the newest file to stay: %v", err)
+	}
+}
+
+func TestDownloadVerified_StoreHitMarksUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plugin"))
+	}))
+	defer server.Close()
+
+	StoreDirectory = t.TempDir()
+	t.Cleanup(func() { StoreDirectory = "" })
+	sum := sha256.Sum256([]byte("plugin"))
+	checksum := hex.EncodeToString(sum[:])
+	dest := filepath.Join(t.TempDir(), "plugin.jar")
+
+	if err := DownloadVerified(server.URL, dest, "sha256", checksum, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored := filepath.Join(StoreDirectory, "sha256", checksum)
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(stored, old, old)
+
+	if err := DownloadVerified(server.URL, dest, "sha256", checksum, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := os.Stat(stored); time.Since(info.ModTime()) > time.Hour {
+		t.Errorf("expected reusing the file to mark it used, last use %s", info.ModTime())
+	}
+}
+
+func TestOffline_RequestsFailWithoutReachingTheServer(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	Offline = true
+	t.Cleanup(func() { Offline = false })
+
+	_, err := HTTPClient.Get(server.URL)
+
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline, got %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("expected no request to reach the server, got %d", requests.Load())
+	}
+}
+
+func TestCache_OfflineKeepsExpiredValues(t *testing.T) {
+	if err := InitCacheDB(t.TempDir()); err != nil {
+		t.Fatalf("failed to init cache DB: %v", err)
+	}
+	defer CloseCache()
+	cache := InitCache("test")
+	cache.SetWithTTL("key", "value", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	var result string
+	if cache.Get("key", &result) {
+		t.Fatal("expected an expired value to miss online")
+	}
+
+	Offline = true
+	t.Cleanup(func() { Offline = false })
+
+	if !cache.Get("key", &result) || result != "value" {
+		t.Errorf("expected the expired value offline, got %q", result)
+	}
+}
+
+func TestDownloadVerified_OfflineUsesTheStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plugin"))
+	}))
+	defer server.Close()
+
+	StoreDirectory = t.TempDir()
+	t.Cleanup(func() {
+		StoreDirectory = ""
+		Offline = false
+	})
+	sum := sha256.Sum256([]byte("plugin"))
+	checksum := hex.EncodeToString(sum[:])
+	if err := StoreDownload(server.URL, "sha256", checksum, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	Offline = true
+	dest := filepath.Join(t.TempDir(), "plugin.jar")
+
+	if err := DownloadVerified(server.URL, dest, "sha256", checksum, nil); err != nil {
+		t.Fatalf("expected the stored file offline, got %v", err)
+	}
+	if content, _ := os.ReadFile(dest); string(content) != "plugin" {
+		t.Errorf("expected the stored content, got %q", content)
+	}
+
+	other := sha256.Sum256([]byte("other"))
+	err := DownloadVerified(server.URL, dest, "sha256", hex.EncodeToString(other[:]), nil)
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline for a file missing from the store, got %v", err)
+	}
+}
+
+func TestCacheStats_KeepsHitsAcrossRuns(t *testing.T) {
+	dir := t.TempDir()
+	if err := InitCacheDB(dir); err != nil {
+		t.Fatalf("failed to init cache DB: %v", err)
+	}
+	cache := InitCache("test")
+	cache.SetPermanent("key", "value")
+	var result string
+	cache.Get("key", &result)
+	cache.Get("missing", &result)
+	CloseCache()
+
+	InitCacheDB(dir)
+	defer CloseCache()
+	InitCache("test").Get("key", &result)
+	stats, err := CacheStats()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected one namespace, got %+v", stats)
+	}
+	if s := stats[0]; s.Namespace != "test" || s.Entries != 1 || s.Hits != 2 || s.Misses != 1 {
+		t.Errorf("expected 1 entry, 2 hits and 1 miss in test, got %+v", s)
+	}
+}
+
+func TestPruneCache_RemovesExpiredAndStaleFileHashes(t *testing.T) {
+	dir := t.TempDir()
+	if err := InitCacheDB(dir); err != nil {
+		t.Fatalf("failed to init cache DB: %v", err)
+	}
+	defer CloseCache()
+	cache := InitCache("test")
+	cache.SetWithTTL("expired", "value", time.Nanosecond)
+	cache.SetValidated("revalidatable", "value", time.Nanosecond, Validators{ETag: `"v1"`})
+	cache.SetPermanent("permanent", "value")
+	kept := filepath.Join(dir, "kept.jar")
+	deleted := filepath.Join(dir, "deleted.jar")
+	os.WriteFile(kept, []byte("kept"), 0644)
+	os.WriteFile(deleted, []byte("deleted"), 0644)
+	CalculateFileSHA256(kept)
+	CalculateFileSHA256(deleted)
+	os.Remove(deleted)
+	time.Sleep(time.Millisecond)
+
+	expired, stale, err := PruneCache()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expired != 1 || stale != 1 {
+		t.Errorf("expected 1 expired entry and 1 stale hash, got %d and %d", expired, stale)
+	}
+	entries, _ := CacheEntries("")
+	if len(entries) != 3 {
+		t.Errorf("expected the revalidatable and permanent entries and the kept file hash to remain, got %+v", entries)
+	}
+}
+
+func TestClearCache_OnlyClearsTheNamespace(t *testing.T) {
+	if err := InitCacheDB(t.TempDir()); err != nil {
+		t.Fatalf("failed to init cache DB: %v", err)
+	}
+	defer CloseCache()
+	InitCache("plugins").SetPermanent("key", "value")
+	InitCache("server").SetPermanent("key", "value")
+
+	cleared, err := ClearCache("plugins")
+
+	if err != nil || cleared != 1 {
+		t.Fatalf("expected 1 cleared entry, got %d (%v)", cleared, err)
+	}
+	entries, _ := CacheEntries("")
+	if len(entries) != 1 || entries[0].Namespace != "server" {
+		t.Errorf("expected only the server entry to remain, got %+v", entries)
+	}
+}
+
+func TestCachedGet_RevalidatesExpiredValuesWithETag(t *testing.T) {
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`"1.0.0"`))
+	}))
+	defer server.Close()
+
+	if err := InitCacheDB(t.TempDir()); err != nil {
+		t.Fatalf("failed to init cache DB: %v", err)
+	}
+	defer CloseCache()
+	cache := InitCache("test")
+	decode := func(r *http.Response, dest interface{}) error {
+		if r.StatusCode != http.StatusOK {
+			return fmt.Errorf("got %d", r.StatusCode)
+		}
+		return json.NewDecoder(r.Body).Decode(dest)
+	}
+	get := func(ttl time.Duration) string {
+		t.Helper()
+		var version string
+		if err := CachedGet(cache, "latest", ttl, Endpoint{URL: server.URL}, "/latest", &version, decode); err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		return version
+	}
+
+	get(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if version := get(time.Hour); version != "1.0.0" {
+		t.Errorf("expected the revalidated value, got %q", version)
+	}
+	if notModified != 1 {
+		t.Errorf("expected the expired value to be revalidated, got %d 304s", notModified)
+	}
+
+	if version := get(time.Hour); version != "1.0.0" || requests != 2 {
+		t.Errorf("expected the 304 to extend the value for the TTL, got %q after %d requests", version, requests)
+	}
+}
@@ -0,0 +1,159 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileName is the file at the root of a workspace listing its member servers,
+// e.g. the proxy, lobby and game servers of a network.
+const FileName = "plugstep-workspace.toml"
+
+const defaultJobs = 4
+
+// Workspace is a set of server directories installed together.
+type Workspace struct {
+	// Root is the directory containing plugstep-workspace.toml.
+	Root string
+	// Members are the server directories, slash separated and relative to Root.
+	Members []string
+	// Jobs is how many members are worked on at once.
+	Jobs int
+}
+
+type workspaceFile struct {
+	Members []string `toml:"members"`
+	Jobs    int      `toml:"jobs"`
+}
+
+// Find looks for plugstep-workspace.toml in dir and its parents.
+func Find(dir string) (*Workspace, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("%s not found in this directory or any parent", FileName)
+		}
+		dir = parent
+	}
+}
+
+// Load reads a workspace file. Members may be glob patterns, which match the
+// directories that contain a plugstep.toml.
+func Load(path string) (*Workspace, error) {
+	var file workspaceFile
+	md, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+	}
+	if file.Jobs < 0 {
+		return nil, fmt.Errorf("%s: jobs must be at least 1", path)
+	}
+
+	root, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Workspace{Root: root, Jobs: file.Jobs}
+	if w.Jobs == 0 {
+		w.Jobs = defaultJobs
+	}
+
+	for _, member := range file.Members {
+		members, err := w.expand(member)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, m := range members {
+			if !slices.Contains(w.Members, m) {
+				w.Members = append(w.Members, m)
+			}
+		}
+	}
+	if len(w.Members) == 0 {
+		return nil, fmt.Errorf("%s lists no members", path)
+	}
+	return w, nil
+}
+
+func (w *Workspace) expand(member string) ([]string, error) {
+	if filepath.IsAbs(member) {
+		return nil, fmt.Errorf("member %s must be relative to the workspace", member)
+	}
+	if !strings.ContainsAny(member, "*?[") {
+		return []string{filepath.ToSlash(filepath.Clean(member))}, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(w.Root, filepath.FromSlash(member)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid member pattern %s: %w", member, err)
+	}
+
+	var members []string
+	for _, match := range matches {
+		if _, err := os.Stat(filepath.Join(match, "plugstep.toml")); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(w.Root, match)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, filepath.ToSlash(rel))
+	}
+	return members, nil
+}
+
+// Dir returns the server directory of a member.
+func (w *Workspace) Dir(member string) string {
+	return filepath.Join(w.Root, filepath.FromSlash(member))
+}
+
+// Result is the outcome of running a function for one member.
+type Result struct {
+	Member   string
+	Err      error
+	Duration time.Duration
+}
+
+// Run calls fn for every member, Jobs at a time, and returns the results in
+// the order of Members.
+func (w *Workspace) Run(fn func(member, dir string) error) []Result {
+	results := make([]Result, len(w.Members))
+	jobs := make(chan struct{}, max(w.Jobs, 1))
+
+	var wg sync.WaitGroup
+	for i, member := range w.Members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobs <- struct{}{}
+			defer func() { <-jobs }()
+
+			start := time.Now()
+			err := fn(member, w.Dir(member))
+			results[i] = Result{Member: member, Err: err, Duration: time.Since(start)}
+		}()
+	}
+	wg.Wait()
+	return results
+}
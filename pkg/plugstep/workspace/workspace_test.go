@@ -0,0 +1,109 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeWorkspace(t *testing.T, content string, members ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, FileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range members {
+		dir := filepath.Join(root, filepath.FromSlash(member))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "plugstep.toml"), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestLoad_ExpandsGlobsAndDeduplicates(t *testing.T) {
+	root := writeWorkspace(t, `members = ["proxy", "games/*", "games/survival"]`,
+		"proxy", "games/survival", "games/skyblock")
+	os.MkdirAll(filepath.Join(root, "games", "notes"), 0755)
+
+	w, err := Load(filepath.Join(root, FileName))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"proxy", "games/skyblock", "games/survival"}
+	if !slices.Equal(w.Members, expected) {
+		t.Errorf("expected members %v, got %v", expected, w.Members)
+	}
+	if w.Jobs != defaultJobs {
+		t.Errorf("expected %d jobs by default, got %d", defaultJobs, w.Jobs)
+	}
+}
+
+func TestLoad_RejectsUnknownKeys(t *testing.T) {
+	root := writeWorkspace(t, "members = [\"lobby\"]\nparallel = 2", "lobby")
+
+	if _, err := Load(filepath.Join(root, FileName)); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestLoad_NoMembers(t *testing.T) {
+	root := writeWorkspace(t, `members = ["servers/*"]`)
+
+	if _, err := Load(filepath.Join(root, FileName)); err == nil {
+		t.Error("expected an error for a workspace without members")
+	}
+}
+
+func TestFind_SearchesParents(t *testing.T) {
+	root := writeWorkspace(t, `members = ["lobby"]`, "lobby")
+
+	w, err := Find(filepath.Join(root, "lobby"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Dir("lobby") != filepath.Join(root, "lobby") {
+		t.Errorf("expected member dir under %s, got %s", root, w.Dir("lobby"))
+	}
+}
+
+func TestFind_NotFound(t *testing.T) {
+	if _, err := Find(t.TempDir()); err == nil {
+		t.Error("expected an error outside a workspace")
+	}
+}
+
+func TestRun_LimitsJobsAndKeepsOrder(t *testing.T) {
+	w := &Workspace{Root: t.TempDir(), Members: []string{"a", "b", "c", "d", "e"}, Jobs: 2}
+
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	results := w.Run(func(member, dir string) error {
+		n := running.Add(1)
+		mu.Lock()
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+
+	if peak.Load() > 2 {
+		t.Errorf("expected at most 2 members at once, got %d", peak.Load())
+	}
+	for i, r := range results {
+		if r.Member != w.Members[i] {
+			t.Errorf("expected result %d for %s, got %s", i, w.Members[i], r.Member)
+		}
+	}
+}